@@ -140,9 +140,10 @@ func buildColumn2(ctx *context, c *TableColumn) (string, error) {
 		return "", nil
 	}
 	seg := &Segment{
-		Raw:    c.Raw,
-		Args:   c.Args,
-		Tables: []Table{c.Table},
+		Raw:     c.Raw,
+		Args:    c.Args,
+		Tables:  []Table{c.Table},
+		Columns: c.Columns,
 	}
 	ctx = newSegmentContext(ctx.global, seg)
 	built, err := build(ctx)
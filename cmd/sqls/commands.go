@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/syntax"
+	"github.com/qjebbs/go-sqls/util"
+)
+
+func cmdBuild(d *definition, bindVar string, w io.Writer) error {
+	query, args, err := build(d, bindVar)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, query)
+	fmt.Fprintln(w, args)
+	return nil
+}
+
+func cmdInterpolate(d *definition, bindVar string, w io.Writer) error {
+	query, args, err := build(d, bindVar)
+	if err != nil {
+		return err
+	}
+	interpolated, err := util.Interpolate(query, args)
+	if err != nil {
+		return fmt.Errorf("interpolate: %w", err)
+	}
+	fmt.Fprintln(w, interpolated)
+	return nil
+}
+
+func cmdTree(d *definition, _ string, w io.Writer) error {
+	if err := d.validate(); err != nil {
+		return err
+	}
+	return printTree(w, d, "segment", 0)
+}
+
+func build(d *definition, bindVar string) (query string, args []any, err error) {
+	style, err := parseBindVarStyle(bindVar)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := d.validate(); err != nil {
+		return "", nil, err
+	}
+	args = make([]any, 0)
+	ctx := sqls.NewContext(&args)
+	ctx.BindVarStyle = style
+	query, err = d.Segment().BuildContext(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", d.errorPosition(), err)
+	}
+	return query, args, nil
+}
+
+func parseBindVarStyle(s string) (syntax.BindVarStyle, error) {
+	switch s {
+	case "":
+		return syntax.Auto, nil
+	case "$":
+		return syntax.Dollar, nil
+	case "?":
+		return syntax.Question, nil
+	default:
+		return 0, fmt.Errorf("%w: invalid bindvar style '%s'", errUsage, s)
+	}
+}
+
+// printTree prints the parsed clauses of the definition tree, e.g.:
+//
+//	segment: "#c1 = $1"
+//	  1:1   func    c("1")
+//	  1:4   plain   " = "
+//	  1:7   bindvar $1
+//	  columns[1]: "#t1.id"
+//	    1:1   func    t("1")
+//	    1:4   plain   ".id"
+func printTree(w io.Writer, d *definition, name string, depth int) error {
+	indent := strings.Repeat("  ", depth)
+	if d == nil {
+		fmt.Fprintf(w, "%s%s: <nil>\n", indent, name)
+		return nil
+	}
+	fmt.Fprintf(w, "%s%s: %q\n", indent, name, d.Raw)
+	if err := printClause(w, d.Raw, depth+1); err != nil {
+		return fmt.Errorf("%s: %w", d.pos, err)
+	}
+	for i, c := range d.Columns {
+		name := fmt.Sprintf("columns[%d]", i+1)
+		if c == nil {
+			fmt.Fprintf(w, "%s  %s: <nil>\n", indent, name)
+			continue
+		}
+		fmt.Fprintf(w, "%s  %s: %q\n", indent, name, c.Raw)
+		if err := printClause(w, c.Raw, depth+2); err != nil {
+			return fmt.Errorf("%s: %w", c.pos, err)
+		}
+	}
+	for i, s := range d.Segments {
+		if err := printTree(w, s, fmt.Sprintf("segments[%d]", i+1), depth+1); err != nil {
+			return err
+		}
+	}
+	for i, b := range d.Builders {
+		if err := printTree(w, b, fmt.Sprintf("builders[%d]", i+1), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printClause(w io.Writer, raw string, depth int) error {
+	clause, err := syntax.Parse(raw)
+	if err != nil {
+		return err
+	}
+	indent := strings.Repeat("  ", depth)
+	for _, expr := range clause.ExprList {
+		var kind, desc string
+		switch expr := expr.(type) {
+		case *syntax.PlainExpr:
+			kind, desc = "plain", fmt.Sprintf("%q", expr.Text)
+		case *syntax.BindVarExpr:
+			kind = "bindvar"
+			if expr.Type == syntax.Question {
+				desc = fmt.Sprintf("? (%d)", expr.Index)
+			} else {
+				desc = fmt.Sprintf("$%d", expr.Index)
+			}
+		case *syntax.FuncCallExpr:
+			args := make([]string, 0, len(expr.Args))
+			for _, arg := range expr.Args {
+				args = append(args, fmt.Sprintf("%q", arg))
+			}
+			kind, desc = "func", fmt.Sprintf("%s(%s)", expr.Name, strings.Join(args, ", "))
+		case *syntax.FuncExpr:
+			kind, desc = "ref", expr.Name
+		default:
+			kind, desc = "unknown", fmt.Sprintf("%T", expr)
+		}
+		fmt.Fprintf(w, "%s%-5s %-7s %s\n", indent, expr.Pos(), kind, desc)
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/codec"
+	"github.com/qjebbs/go-sqls/syntax"
+	"gopkg.in/yaml.v3"
+)
+
+// definition is the definition of a segment, decoded from JSON or YAML.
+type definition struct {
+	Raw      string              `yaml:"raw"`
+	Prefix   string              `yaml:"prefix"`
+	Suffix   string              `yaml:"suffix"`
+	Args     []any               `yaml:"args"`
+	Columns  []*columnDefinition `yaml:"columns"`
+	Tables   []sqls.Table        `yaml:"tables"`
+	Segments []*definition       `yaml:"segments"`
+	Builders []*definition       `yaml:"builders"`
+
+	pos position // position of the raw
+}
+
+// columnDefinition is the definition of a table column.
+type columnDefinition struct {
+	Table sqls.Table `yaml:"table"`
+	Raw   string     `yaml:"raw"`
+	Args  []any      `yaml:"args"`
+
+	pos position // position of the raw
+}
+
+// position is the position of a value in the definition file.
+type position struct {
+	file      string
+	line, col int
+}
+
+func (p position) String() string {
+	if p.line == 0 {
+		return p.file
+	}
+	return fmt.Sprintf("%s:%d:%d", p.file, p.line, p.col)
+}
+
+// readDefinition reads the definition from r, the name is used in error
+// messages to report positions. JSON is accepted since it's a subset of YAML.
+func readDefinition(name string, r io.Reader) (*definition, error) {
+	root := new(yaml.Node)
+	if err := yaml.NewDecoder(r).Decode(root); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s: empty definition", name)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if hasKey(root, "version") {
+		// the serialized form of codec package
+		doc := new(codec.Document)
+		if err := root.Decode(doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		s, err := codec.Decode(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		d, err := definitionOf(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		d.setFile(name)
+		return d, nil
+	}
+	d := new(definition)
+	if err := root.Decode(d); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	d.setFile(name)
+	return d, nil
+}
+
+// definitionOf converts the segment to definition, the positions are
+// unknown since the segment is decoded by the codec package.
+func definitionOf(s *sqls.Segment) (*definition, error) {
+	if s == nil {
+		return nil, nil
+	}
+	d := &definition{
+		Raw:    s.Raw,
+		Prefix: s.Prefix,
+		Suffix: s.Suffix,
+		Args:   s.Args,
+		Tables: s.Tables,
+	}
+	for _, c := range s.Columns {
+		if c == nil {
+			d.Columns = append(d.Columns, nil)
+			continue
+		}
+		d.Columns = append(d.Columns, &columnDefinition{
+			Table: c.Table,
+			Raw:   c.Raw,
+			Args:  c.Args,
+		})
+	}
+	for _, seg := range s.Segments {
+		sub, err := definitionOf(seg)
+		if err != nil {
+			return nil, err
+		}
+		d.Segments = append(d.Segments, sub)
+	}
+	for i, b := range s.Builders {
+		seg, ok := b.(*sqls.Segment)
+		if !ok {
+			return nil, fmt.Errorf("builder %d of '%s': unsupported builder %T", i+1, s.Raw, b)
+		}
+		sub, err := definitionOf(seg)
+		if err != nil {
+			return nil, err
+		}
+		d.Builders = append(d.Builders, sub)
+	}
+	return d, nil
+}
+
+// hasKey tells if the root mapping of the document has the key.
+func hasKey(node *yaml.Node, key string) bool {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return false
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *definition) UnmarshalYAML(node *yaml.Node) error {
+	type plain definition
+	if err := node.Decode((*plain)(d)); err != nil {
+		return err
+	}
+	d.pos = valuePosition(node, "raw")
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *columnDefinition) UnmarshalYAML(node *yaml.Node) error {
+	type plain columnDefinition
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	c.pos = valuePosition(node, "raw")
+	return nil
+}
+
+// valuePosition returns the position of the value of key in the mapping
+// node, or the position of the node itself if the key is not found.
+func valuePosition(node *yaml.Node, key string) position {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				v := node.Content[i+1]
+				return position{line: v.Line, col: v.Column}
+			}
+		}
+	}
+	return position{line: node.Line, col: node.Column}
+}
+
+func (d *definition) setFile(name string) {
+	if d == nil {
+		return
+	}
+	d.pos.file = name
+	for _, c := range d.Columns {
+		if c != nil {
+			c.pos.file = name
+		}
+	}
+	for _, s := range d.Segments {
+		s.setFile(name)
+	}
+	for _, b := range d.Builders {
+		b.setFile(name)
+	}
+}
+
+// validate parses all the raws in the definition tree, so that the syntax
+// errors are reported with their positions in the definition file.
+func (d *definition) validate() error {
+	if d == nil {
+		return nil
+	}
+	if _, err := syntax.Parse(d.Raw); err != nil {
+		return fmt.Errorf("%s: %w", d.pos, err)
+	}
+	for _, c := range d.Columns {
+		if c == nil {
+			continue
+		}
+		if _, err := syntax.Parse(c.Raw); err != nil {
+			return fmt.Errorf("%s: %w", c.pos, err)
+		}
+	}
+	for _, s := range d.Segments {
+		if err := s.validate(); err != nil {
+			return err
+		}
+	}
+	for _, b := range d.Builders {
+		if err := b.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errorPosition returns the position of the innermost segment or column
+// which fails to build, so that the build errors are reported with their
+// positions like the syntax errors. A subtree fails to build on its own
+// if and only if it fails in the tree, since the building context of each
+// segment is independent.
+func (d *definition) errorPosition() position {
+	for _, c := range d.Columns {
+		if c == nil {
+			continue
+		}
+		s := &sqls.Segment{
+			Raw:     "#c1",
+			Columns: []*sqls.TableColumn{c.Table.Expression(c.Raw, c.Args...)},
+		}
+		if _, _, err := s.Build(); err != nil {
+			return c.pos
+		}
+	}
+	for _, list := range [][]*definition{d.Segments, d.Builders} {
+		for _, sub := range list {
+			if sub == nil {
+				continue
+			}
+			if _, _, err := sub.Segment().Build(); err != nil {
+				return sub.errorPosition()
+			}
+		}
+	}
+	return d.pos
+}
+
+// Segment converts the definition to *sqls.Segment.
+func (d *definition) Segment() *sqls.Segment {
+	if d == nil {
+		return nil
+	}
+	s := &sqls.Segment{
+		Raw:    d.Raw,
+		Prefix: d.Prefix,
+		Suffix: d.Suffix,
+		Args:   d.Args,
+		Tables: d.Tables,
+	}
+	for _, c := range d.Columns {
+		if c == nil {
+			s.AppendColumns(nil)
+			continue
+		}
+		s.AppendColumns(c.Table.Expression(c.Raw, c.Args...))
+	}
+	for _, seg := range d.Segments {
+		s.AppendSegments(seg.Segment())
+	}
+	for _, b := range d.Builders {
+		s.Builders = append(s.Builders, b.Segment())
+	}
+	return s
+}
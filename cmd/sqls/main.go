@@ -0,0 +1,107 @@
+// Command sqls renders segment definitions from the command line, which
+// helps to reproduce and debug the building of segments without writing Go.
+//
+// Usage:
+//
+//	sqls [flags] <command> [file]
+//
+// The commands are:
+//
+//	build        print the built query and its args
+//	interpolate  print the built query with args interpolated
+//	tree         print the parsed clauses of the segment tree
+//
+// The segment definition is read from the file, or from stdin if the file
+// is omitted or "-". Both JSON and YAML are accepted, for example:
+//
+//	raw: "SELECT #join('#c', ', ') FROM #t1 WHERE #s1"
+//	tables: [users]
+//	columns:
+//	  - {table: users, raw: "#t1.id"}
+//	  - {table: users, raw: "#t1.name"}
+//	segments:
+//	  - raw: "#c1 = $1"
+//	    columns: [{table: users, raw: "#t1.id"}]
+//	    args: [1]
+//
+// The serialized documents of the codec package are also accepted, which
+// are distinguished by the "version" field.
+//
+// The flags are:
+//
+//	-bindvar string
+//		bindvar style of the built query, "$" or "?" (default: auto detect)
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("sqls", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: sqls [flags] build|interpolate|tree [file]")
+		flags.PrintDefaults()
+	}
+	bindVar := flags.String("bindvar", "", `bindvar style of the built query, "$" or "?" (default: auto detect)`)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return 2
+	}
+	cmd, file := flags.Arg(0), flags.Arg(1)
+	err := execute(cmd, file, *bindVar, stdin, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "sqls: %s\n", err)
+		if errors.Is(err, errUsage) {
+			flags.Usage()
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+var errUsage = errors.New("bad usage")
+
+func execute(cmd, file, bindVar string, stdin io.Reader, stdout io.Writer) error {
+	var cmdFn func(d *definition, bindVar string, w io.Writer) error
+	switch cmd {
+	case "build":
+		cmdFn = cmdBuild
+	case "interpolate":
+		cmdFn = cmdInterpolate
+	case "tree":
+		cmdFn = cmdTree
+	default:
+		return fmt.Errorf("%w: unknown command '%s'", errUsage, cmd)
+	}
+	name := file
+	r := stdin
+	if file == "" || file == "-" {
+		name = "<stdin>"
+	} else {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	d, err := readDefinition(name, r)
+	if err != nil {
+		return err
+	}
+	return cmdFn(d, bindVar, stdout)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		args     []string
+		input    string
+		want     string
+		wantErr  string
+		wantCode int
+	}{
+		{
+			name: "build yaml",
+			args: []string{"build"},
+			input: `
+raw: "SELECT #c1 FROM #t1 WHERE #s1"
+tables: [users]
+columns: [{table: users, raw: "#t1.id"}]
+segments:
+  - raw: "#c1 IN (#join('#$', ', '))"
+    columns: [{table: users, raw: "#t1.id"}]
+    args: [1, 2]
+`,
+			want: "SELECT users.id FROM users WHERE users.id IN ($1, $2)\n[1 2]\n",
+		},
+		{
+			name:  "build json with bindvar",
+			args:  []string{"-bindvar", "?", "build", "-"},
+			input: `{"raw": "#c1 = $1", "columns": [{"table": "u", "raw": "#t1.name"}], "args": ["alice"]}`,
+			want:  "u.name = ?\n[alice]\n",
+		},
+		{
+			name:  "interpolate",
+			args:  []string{"interpolate"},
+			input: `{"raw": "#c1 = $1 AND #c2 = $2", "columns": [{"table": "u", "raw": "#t1.name"}, {"table": "u", "raw": "#t1.active"}], "args": ["alice", true]}`,
+			want:  "u.name = 'alice' AND u.active = TRUE\n",
+		},
+		{
+			name:  "tree",
+			args:  []string{"tree"},
+			input: `{"raw": "#c1 = $1", "columns": [{"table": "u", "raw": "#t1.id"}], "args": [1]}`,
+			want: `segment: "#c1 = $1"
+  1:1   func    c("1")
+  1:4   plain   " = "
+  1:7   bindvar $1
+  columns[1]: "#t1.id"
+    1:1   func    t("1")
+    1:4   plain   ".id"
+`,
+		},
+		{
+			name: "syntax error position",
+			args: []string{"build"},
+			input: `
+raw: "#s1"
+segments:
+  - raw: "a = $1, ?"
+    args: [1]
+`,
+			wantErr:  "sqls: <stdin>:4:10: ",
+			wantCode: 1,
+		},
+		{
+			name: "build error position",
+			args: []string{"build"},
+			input: `
+raw: "SELECT #s1"
+segments:
+  - raw: "#c1, #s1"
+    columns: [{table: u, raw: "#t1.id"}]
+    segments:
+      - raw: "#c1"
+`,
+			wantErr:  "sqls: <stdin>:7:14: build 'SELECT #s1': ",
+			wantCode: 1,
+		},
+		{
+			name: "column build error position",
+			args: []string{"build"},
+			input: `
+raw: "SELECT #c1"
+columns:
+  - table: u
+    raw: "#t1.id = $1"
+`,
+			wantErr:  "sqls: <stdin>:5:10: ",
+			wantCode: 1,
+		},
+		{
+			name:  "codec document",
+			args:  []string{"build"},
+			input: `{"version": 1, "segment": {"raw": "#c1 > $1", "columns": [{"table": "u", "raw": "#t1.id"}], "args": [{"type": "int64", "value": 1}]}}`,
+			want:  "u.id > $1\n[1]\n",
+		},
+		{
+			name:     "unused arg",
+			args:     []string{"build"},
+			input:    `{"raw": "a = 1", "args": [1]}`,
+			wantErr:  "arg 1 is not used",
+			wantCode: 1,
+		},
+		{
+			name:     "unknown command",
+			args:     []string{"foo"},
+			wantErr:  "unknown command 'foo'",
+			wantCode: 2,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+			code := run(tc.args, strings.NewReader(tc.input), stdout, stderr)
+			if code != tc.wantCode {
+				t.Fatalf("got exit code %d, want %d, stderr:\n%s", code, tc.wantCode, stderr)
+			}
+			if tc.wantErr != "" {
+				if !strings.Contains(stderr.String(), tc.wantErr) {
+					t.Errorf("got stderr:\n%s\nwant containing:\n%s", stderr, tc.wantErr)
+				}
+				return
+			}
+			if stdout.String() != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", stdout, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Package codec provides the serialized form of segment trees, so that the
+// segments can be stored and rebuilt later, e.g. saved search filters.
+//
+// The args are encoded with their types, and the builders with their kinds,
+// both can be extended by registering to a Registry:
+//
+//	{
+//	  "version": 1,
+//	  "segment": {
+//	    "raw": "#c1 > $1",
+//	    "columns": [{"table": "u", "raw": "#t1.created_at"}],
+//	    "args": [{"type": "time", "value": "2023-01-01T00:00:00Z"}]
+//	  }
+//	}
+//
+// The Document can be encoded in both JSON and YAML.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/qjebbs/go-sqls"
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the version of the serialization format produced by this
+// package. Documents of higher versions are refused to decode.
+const Version = 1
+
+// Document is the serialized form of a segment tree.
+type Document struct {
+	Version int      `json:"version" yaml:"version"`
+	Segment *Segment `json:"segment" yaml:"segment"`
+}
+
+// Segment is the serialized form of *sqls.Segment.
+type Segment struct {
+	Raw      string       `json:"raw" yaml:"raw"`
+	Prefix   string       `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	Suffix   string       `json:"suffix,omitempty" yaml:"suffix,omitempty"`
+	Args     []*Value     `json:"args,omitempty" yaml:"args,omitempty"`
+	Columns  []*Column    `json:"columns,omitempty" yaml:"columns,omitempty"`
+	Tables   []sqls.Table `json:"tables,omitempty" yaml:"tables,omitempty"`
+	Segments []*Segment   `json:"segments,omitempty" yaml:"segments,omitempty"`
+	Builders []*Builder   `json:"builders,omitempty" yaml:"builders,omitempty"`
+}
+
+// Column is the serialized form of *sqls.TableColumn.
+type Column struct {
+	Table   sqls.Table `json:"table,omitempty" yaml:"table,omitempty"`
+	Raw     string     `json:"raw" yaml:"raw"`
+	Args    []*Value   `json:"args,omitempty" yaml:"args,omitempty"`
+	Columns []*Column  `json:"columns,omitempty" yaml:"columns,omitempty"`
+}
+
+// Value is the serialized form of an arg. The Value is the encoded value
+// of the registered arg type. If Type is empty, the Value is used as is,
+// which is convenient for hand-written documents.
+type Value struct {
+	Type  string `json:"type,omitempty" yaml:"type,omitempty"`
+	Value any    `json:"value" yaml:"value"`
+}
+
+// Builder is the serialized form of sqls.Builder. The Segment is used
+// for the builtin kind "segment", and the Value for registered kinds.
+type Builder struct {
+	Kind    string   `json:"kind" yaml:"kind"`
+	Segment *Segment `json:"segment,omitempty" yaml:"segment,omitempty"`
+	Value   any      `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// Encode encodes the segment tree with the DefaultRegistry.
+func Encode(s *sqls.Segment) (*Document, error) {
+	return DefaultRegistry.Encode(s)
+}
+
+// Decode decodes the document with the DefaultRegistry.
+func Decode(d *Document) (*sqls.Segment, error) {
+	return DefaultRegistry.Decode(d)
+}
+
+// MarshalJSON encodes the segment tree to JSON with the DefaultRegistry.
+func MarshalJSON(s *sqls.Segment) ([]byte, error) {
+	d, err := Encode(s)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	// keep operators like '<' readable
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(d); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// UnmarshalJSON decodes the segment tree from JSON with the DefaultRegistry.
+func UnmarshalJSON(data []byte) (*sqls.Segment, error) {
+	d := new(Document)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// keep the precision of large integers
+	dec.UseNumber()
+	if err := dec.Decode(d); err != nil {
+		return nil, fmt.Errorf("unmarshal document: %w", err)
+	}
+	return Decode(d)
+}
+
+// MarshalYAML encodes the segment tree to YAML with the DefaultRegistry.
+func MarshalYAML(s *sqls.Segment) ([]byte, error) {
+	d, err := Encode(s)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(d)
+}
+
+// UnmarshalYAML decodes the segment tree from YAML with the DefaultRegistry.
+func UnmarshalYAML(data []byte) (*sqls.Segment, error) {
+	d := new(Document)
+	if err := yaml.Unmarshal(data, d); err != nil {
+		return nil, fmt.Errorf("unmarshal document: %w", err)
+	}
+	return Decode(d)
+}
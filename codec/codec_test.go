@@ -0,0 +1,235 @@
+package codec_test
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/codec"
+)
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+	var alias sqls.Table = "t"
+	segment := &sqls.Segment{
+		Raw:    "#c1 = $1 AND #s1 AND #s2 AND id IN (#b1)",
+		Prefix: "WHERE",
+		Args: []any{
+			int64(math.MaxInt64), uint8(8), float32(1.5), 2.5, "a", true, nil,
+			[]byte("bytes"), time.Date(2023, 1, 2, 3, 4, 5, 6, time.UTC),
+		},
+		Columns: []*sqls.TableColumn{
+			alias.Expression("COALESCE(#t1.id, $1)", int32(0)),
+			nil,
+		},
+		Tables:   []sqls.Table{"table", alias},
+		Segments: []*sqls.Segment{{Raw: "1=1"}, nil},
+		Builders: []sqls.Builder{
+			&sqls.Segment{Raw: "SELECT id FROM #t1", Tables: []sqls.Table{"foo"}},
+		},
+	}
+	testCases := []struct {
+		name      string
+		marshal   func(*sqls.Segment) ([]byte, error)
+		unmarshal func([]byte) (*sqls.Segment, error)
+	}{
+		{"json", codec.MarshalJSON, codec.UnmarshalJSON},
+		{"yaml", codec.MarshalYAML, codec.UnmarshalYAML},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			data, err := tc.marshal(segment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tc.unmarshal(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, segment) {
+				t.Errorf("got:\n%#v\nwant:\n%#v\ndata:\n%s", got, segment, data)
+			}
+		})
+	}
+}
+
+func TestUntyped(t *testing.T) {
+	t.Parallel()
+	want := []any{int64(1), 1.5, "a", true, nil}
+	got, err := codec.UnmarshalJSON([]byte(`{
+		"version": 1,
+		"segment": {"raw": "$1 $2 $3 $4 $5", "args": [{"value": 1}, {"value": 1.5}, {"value": "a"}, {"value": true}, {"value": null}]}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Args, want) {
+		t.Errorf("got %#v, want %#v", got.Args, want)
+	}
+	got, err = codec.UnmarshalYAML([]byte(`
+version: 1
+segment:
+  raw: $1 $2 $3 $4 $5
+  args: [{value: 1}, {value: 1.5}, {value: a}, {value: true}, {value: null}]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Args, want) {
+		t.Errorf("got %#v, want %#v", got.Args, want)
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		typ   string
+		value string
+		want  string
+	}{
+		{typ: "int8", value: "128", want: "128 overflows int8"},
+		{typ: "uint8", value: "256", want: "256 overflows uint8"},
+		{typ: "float32", value: "1e300", want: "1e+300 overflows float32"},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.typ, func(t *testing.T) {
+			t.Parallel()
+			_, err := codec.UnmarshalJSON([]byte(`{
+				"version": 1,
+				"segment": {"raw": "$1", "args": [{"type": "` + tc.typ + `", "value": ` + tc.value + `}]}
+			}`))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("want error %q, got %v", tc.want, err)
+			}
+		})
+	}
+	// YAML decodes the number as float64, which is exactly 1<<63
+	_, err := codec.UnmarshalYAML([]byte(`
+version: 1
+segment: {raw: $1, args: [{type: int64, value: 9223372036854775808.0}]}
+`))
+	if want := "is not an int64"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("want error %q, got %v", want, err)
+	}
+}
+
+type status string
+
+type statusFilter struct {
+	statuses []status
+}
+
+func (f *statusFilter) Build() (string, []any, error) {
+	return f.segment().Build()
+}
+
+func (f *statusFilter) BuildContext(ctx *sqls.Context) (string, error) {
+	return f.segment().BuildContext(ctx)
+}
+
+func (f *statusFilter) segment() *sqls.Segment {
+	args := make([]any, 0, len(f.statuses))
+	for _, s := range f.statuses {
+		args = append(args, string(s))
+	}
+	return &sqls.Segment{Raw: "status IN (#join('#$', ', '))", Args: args}
+}
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+	r := codec.NewRegistry()
+	r.RegisterArgType(&codec.ArgType{
+		Name: "status",
+		Type: reflect.TypeOf(status("")),
+		Encode: func(v any) (any, error) {
+			return string(v.(status)), nil
+		},
+		Decode: func(v any) (any, error) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("want string, got %T", v)
+			}
+			return status(s), nil
+		},
+	})
+	r.RegisterBuilderKind(&codec.BuilderKind{
+		Name: "status_filter",
+		Type: reflect.TypeOf(&statusFilter{}),
+		Encode: func(b sqls.Builder) (any, error) {
+			statuses := make([]any, 0)
+			for _, s := range b.(*statusFilter).statuses {
+				statuses = append(statuses, string(s))
+			}
+			return statuses, nil
+		},
+		Decode: func(v any) (sqls.Builder, error) {
+			list, ok := v.([]any)
+			if !ok {
+				return nil, fmt.Errorf("want list, got %T", v)
+			}
+			f := &statusFilter{}
+			for _, s := range list {
+				f.statuses = append(f.statuses, status(s.(string)))
+			}
+			return f, nil
+		},
+	})
+	segment := &sqls.Segment{
+		Raw:      "$1 AND #b1",
+		Args:     []any{status("a")},
+		Builders: []sqls.Builder{&statusFilter{statuses: []status{"b", "c"}}},
+	}
+	if _, err := codec.Encode(segment); err == nil || !strings.Contains(err.Error(), "arg type codec_test.status is not registered") {
+		t.Errorf("want not registered error for default registry, got %v", err)
+	}
+	doc, err := r.Encode(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Decode(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, segment) {
+		t.Errorf("got:\n%#v\nwant:\n%#v", got, segment)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	t.Parallel()
+	for _, v := range []int{0, codec.Version + 1} {
+		_, err := codec.Decode(&codec.Document{Version: v, Segment: &codec.Segment{Raw: "1"}})
+		if err == nil {
+			t.Errorf("want error for version %d", v)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	t.Parallel()
+	values := []any{time.Date(2023, 1, 2, 3, 4, 5, 6, time.UTC), int64(math.MaxInt64), nil, "a"}
+	token, err := codec.EncodeCursor(values...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(token, "+/=") {
+		t.Errorf("token is not URL safe: %s", token)
+	}
+	got, err := codec.DecodeCursor(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, values) {
+		t.Errorf("got:\n%#v\nwant:\n%#v", got, values)
+	}
+	if _, err := codec.DecodeCursor("not a cursor"); err == nil {
+		t.Error("want error for invalid token")
+	}
+}
@@ -0,0 +1,74 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// cursor is the serialized form of a keyset pagination cursor.
+type cursor struct {
+	Version int      `json:"version"`
+	Values  []*Value `json:"values"`
+}
+
+// EncodeCursor encodes the values of the last scanned row to an opaque
+// cursor token with the DefaultRegistry, see Registry.EncodeCursor.
+func EncodeCursor(values ...any) (string, error) {
+	return DefaultRegistry.EncodeCursor(values...)
+}
+
+// DecodeCursor decodes the cursor token with the DefaultRegistry.
+func DecodeCursor(token string) ([]any, error) {
+	return DefaultRegistry.DecodeCursor(token)
+}
+
+// EncodeCursor encodes the values of the last scanned row to an opaque
+// cursor token, which is URL safe. The values are the ones of the ORDER BY
+// columns, and keep their types after decoding, e.g.:
+//
+//	token, err := r.EncodeCursor(last.CreatedAt, last.ID)
+//	// for the next page
+//	values, err := r.DecodeCursor(token)
+//	b.After(values...)
+func (r *Registry) EncodeCursor(values ...any) (string, error) {
+	args, err := r.encodeArgs(values)
+	if err != nil {
+		return "", fmt.Errorf("encode cursor: %w", err)
+	}
+	data, err := json.Marshal(&cursor{
+		Version: Version,
+		Values:  args,
+	})
+	if err != nil {
+		return "", fmt.Errorf("encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor decodes the cursor token encoded by EncodeCursor.
+func (r *Registry) DecodeCursor(token string) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("decode cursor: %w", err)
+	}
+	c := new(cursor)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// keep the precision of large integers
+	dec.UseNumber()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("decode cursor: %w", err)
+	}
+	if c.Version < 1 || c.Version > Version {
+		return nil, fmt.Errorf("decode cursor: unsupported version %d, want 1 to %d", c.Version, Version)
+	}
+	values, err := r.decodeArgs(c.Values)
+	if err != nil {
+		return nil, fmt.Errorf("decode cursor: %w", err)
+	}
+	if values == nil {
+		values = []any{}
+	}
+	return values, nil
+}
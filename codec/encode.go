@@ -0,0 +1,266 @@
+package codec
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/qjebbs/go-sqls"
+)
+
+const kindSegment = "segment"
+
+// Encode encodes the segment tree to Document.
+func (r *Registry) Encode(s *sqls.Segment) (*Document, error) {
+	seg, err := r.encodeSegment(s)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{
+		Version: Version,
+		Segment: seg,
+	}, nil
+}
+
+// Decode decodes the segment tree from Document.
+func (r *Registry) Decode(d *Document) (*sqls.Segment, error) {
+	if d == nil {
+		return nil, nil
+	}
+	if d.Version < 1 || d.Version > Version {
+		return nil, fmt.Errorf("unsupported document version %d, want 1 to %d", d.Version, Version)
+	}
+	return r.decodeSegment(d.Segment)
+}
+
+func (r *Registry) encodeSegment(s *sqls.Segment) (*Segment, error) {
+	if s == nil {
+		return nil, nil
+	}
+	seg := &Segment{
+		Raw:    s.Raw,
+		Prefix: s.Prefix,
+		Suffix: s.Suffix,
+		Tables: s.Tables,
+	}
+	var err error
+	seg.Args, err = r.encodeArgs(s.Args)
+	if err != nil {
+		return nil, fmt.Errorf("encode '%s': %w", s.Raw, err)
+	}
+	for i, c := range s.Columns {
+		col, err := r.encodeColumn(c)
+		if err != nil {
+			return nil, fmt.Errorf("encode '%s': column %d: %w", s.Raw, i+1, err)
+		}
+		seg.Columns = append(seg.Columns, col)
+	}
+	for _, sub := range s.Segments {
+		encoded, err := r.encodeSegment(sub)
+		if err != nil {
+			return nil, err
+		}
+		seg.Segments = append(seg.Segments, encoded)
+	}
+	for i, b := range s.Builders {
+		builder, err := r.encodeBuilder(b)
+		if err != nil {
+			return nil, fmt.Errorf("encode '%s': builder %d: %w", s.Raw, i+1, err)
+		}
+		seg.Builders = append(seg.Builders, builder)
+	}
+	return seg, nil
+}
+
+func (r *Registry) decodeSegment(seg *Segment) (*sqls.Segment, error) {
+	if seg == nil {
+		return nil, nil
+	}
+	s := &sqls.Segment{
+		Raw:    seg.Raw,
+		Prefix: seg.Prefix,
+		Suffix: seg.Suffix,
+		Tables: seg.Tables,
+	}
+	var err error
+	s.Args, err = r.decodeArgs(seg.Args)
+	if err != nil {
+		return nil, fmt.Errorf("decode '%s': %w", seg.Raw, err)
+	}
+	for i, c := range seg.Columns {
+		col, err := r.decodeColumn(c)
+		if err != nil {
+			return nil, fmt.Errorf("decode '%s': column %d: %w", seg.Raw, i+1, err)
+		}
+		s.AppendColumns(col)
+	}
+	for _, sub := range seg.Segments {
+		decoded, err := r.decodeSegment(sub)
+		if err != nil {
+			return nil, err
+		}
+		s.AppendSegments(decoded)
+	}
+	for i, b := range seg.Builders {
+		builder, err := r.decodeBuilder(b)
+		if err != nil {
+			return nil, fmt.Errorf("decode '%s': builder %d: %w", seg.Raw, i+1, err)
+		}
+		s.Builders = append(s.Builders, builder)
+	}
+	return s, nil
+}
+
+func (r *Registry) encodeColumn(c *sqls.TableColumn) (*Column, error) {
+	if c == nil {
+		return nil, nil
+	}
+	args, err := r.encodeArgs(c.Args)
+	if err != nil {
+		return nil, err
+	}
+	col := &Column{
+		Table: c.Table,
+		Raw:   c.Raw,
+		Args:  args,
+	}
+	for i, nested := range c.Columns {
+		n, err := r.encodeColumn(nested)
+		if err != nil {
+			return nil, fmt.Errorf("encode '%s': column %d: %w", c.Raw, i+1, err)
+		}
+		col.Columns = append(col.Columns, n)
+	}
+	return col, nil
+}
+
+func (r *Registry) decodeColumn(c *Column) (*sqls.TableColumn, error) {
+	if c == nil {
+		return nil, nil
+	}
+	args, err := r.decodeArgs(c.Args)
+	if err != nil {
+		return nil, err
+	}
+	col := &sqls.TableColumn{
+		Table: c.Table,
+		Raw:   c.Raw,
+		Args:  args,
+	}
+	for i, nested := range c.Columns {
+		n, err := r.decodeColumn(nested)
+		if err != nil {
+			return nil, fmt.Errorf("decode '%s': column %d: %w", c.Raw, i+1, err)
+		}
+		col.Columns = append(col.Columns, n)
+	}
+	return col, nil
+}
+
+func (r *Registry) encodeArgs(args []any) ([]*Value, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	values := make([]*Value, 0, len(args))
+	for i, arg := range args {
+		v, err := r.encodeArg(arg)
+		if err != nil {
+			return nil, fmt.Errorf("arg %d: %w", i+1, err)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (r *Registry) decodeArgs(values []*Value) ([]any, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	args := make([]any, 0, len(values))
+	for i, v := range values {
+		arg, err := r.decodeArg(v)
+		if err != nil {
+			return nil, fmt.Errorf("arg %d: %w", i+1, err)
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+func (r *Registry) encodeArg(arg any) (*Value, error) {
+	if arg == nil {
+		return &Value{}, nil
+	}
+	t, ok := r.argTypesByGo[reflect.TypeOf(arg)]
+	if !ok {
+		return nil, fmt.Errorf("arg type %T is not registered", arg)
+	}
+	v, err := t.Encode(arg)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s: %w", t.Name, err)
+	}
+	return &Value{
+		Type:  t.Name,
+		Value: v,
+	}, nil
+}
+
+func (r *Registry) decodeArg(v *Value) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if v.Type == "" {
+		return decodeUntyped(v.Value)
+	}
+	t, ok := r.argTypes[v.Type]
+	if !ok {
+		return nil, fmt.Errorf("arg type '%s' is not registered", v.Type)
+	}
+	arg, err := t.Decode(v.Value)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", t.Name, err)
+	}
+	return arg, nil
+}
+
+func (r *Registry) encodeBuilder(b sqls.Builder) (*Builder, error) {
+	if s, ok := b.(*sqls.Segment); ok {
+		seg, err := r.encodeSegment(s)
+		if err != nil {
+			return nil, err
+		}
+		return &Builder{
+			Kind:    kindSegment,
+			Segment: seg,
+		}, nil
+	}
+	k, ok := r.kindsByGo[reflect.TypeOf(b)]
+	if !ok {
+		return nil, fmt.Errorf("builder kind %T is not registered", b)
+	}
+	v, err := k.Encode(b)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s: %w", k.Name, err)
+	}
+	return &Builder{
+		Kind:  k.Name,
+		Value: v,
+	}, nil
+}
+
+func (r *Registry) decodeBuilder(b *Builder) (sqls.Builder, error) {
+	if b == nil {
+		return nil, fmt.Errorf("nil builder")
+	}
+	if b.Kind == kindSegment {
+		return r.decodeSegment(b.Segment)
+	}
+	k, ok := r.kinds[b.Kind]
+	if !ok {
+		return nil, fmt.Errorf("builder kind '%s' is not registered", b.Kind)
+	}
+	builder, err := k.Decode(b.Value)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", k.Name, err)
+	}
+	return builder, nil
+}
@@ -0,0 +1,47 @@
+package codec_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/codec"
+)
+
+func Example() {
+	var users sqls.Table = "u"
+	filter := &sqls.Segment{
+		Raw: "#c1 > $1 AND #s1",
+		Columns: []*sqls.TableColumn{
+			users.Column("created_at"),
+		},
+		Args: []any{time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Segments: []*sqls.Segment{
+			{
+				Raw:     "#c1 IN (#join('#$', ', '))",
+				Columns: users.Columns("status"),
+				Args:    []any{1, 2},
+			},
+		},
+	}
+	data, err := codec.MarshalJSON(filter)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(data))
+
+	decoded, err := codec.UnmarshalJSON(data)
+	if err != nil {
+		panic(err)
+	}
+	query, args, err := decoded.Build()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(query)
+	fmt.Println(args)
+	// Output:
+	// {"version":1,"segment":{"raw":"#c1 > $1 AND #s1","args":[{"type":"time","value":"2023-01-01T00:00:00Z"}],"columns":[{"table":"u","raw":"#t1.created_at"}],"segments":[{"raw":"#c1 IN (#join('#$', ', '))","args":[{"type":"int","value":1},{"type":"int","value":2}],"columns":[{"table":"u","raw":"#t1.status"}]}]}}
+	// u.created_at > $1 AND u.status IN ($2, $3)
+	// [2023-01-01 00:00:00 +0000 UTC 1 2]
+}
@@ -0,0 +1,96 @@
+package codec
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/qjebbs/go-sqls"
+)
+
+// DefaultRegistry is the registry used by the package level functions,
+// with all the builtin arg types and builder kinds registered.
+var DefaultRegistry = NewRegistry()
+
+// ArgType is the codec of an arg type.
+type ArgType struct {
+	// Name is the name of the type in serialized form, e.g.: "time"
+	Name string
+	// Type is the Go type of the arg.
+	Type reflect.Type
+	// Encode encodes the arg to a JSON / YAML friendly value, e.g.:
+	// string, bool, number, []any, map[string]any or structs.
+	Encode func(v any) (any, error)
+	// Decode decodes the value from its generic form, which could be
+	// produced by either JSON (with json.Number for numbers) or YAML
+	// decoder, e.g.: map[string]any for objects.
+	Decode func(v any) (any, error)
+}
+
+// BuilderKind is the codec of a builder kind.
+type BuilderKind struct {
+	// Name is the name of the kind in serialized form.
+	Name string
+	// Type is the Go type of the builder.
+	Type reflect.Type
+	// Encode encodes the builder to a JSON / YAML friendly value.
+	Encode func(b sqls.Builder) (any, error)
+	// Decode decodes the builder from its generic form, see ArgType.Decode.
+	Decode func(v any) (sqls.Builder, error)
+}
+
+// Registry is the registry of arg types and builder kinds.
+type Registry struct {
+	argTypes     map[string]*ArgType
+	argTypesByGo map[reflect.Type]*ArgType
+	kinds        map[string]*BuilderKind
+	kindsByGo    map[reflect.Type]*BuilderKind
+}
+
+// NewRegistry returns a new Registry with the builtin arg types and
+// builder kinds registered.
+func NewRegistry() *Registry {
+	r := &Registry{
+		argTypes:     make(map[string]*ArgType),
+		argTypesByGo: make(map[reflect.Type]*ArgType),
+		kinds:        make(map[string]*BuilderKind),
+		kindsByGo:    make(map[reflect.Type]*BuilderKind),
+	}
+	for _, t := range builtinArgTypes() {
+		r.RegisterArgType(t)
+	}
+	return r
+}
+
+// RegisterArgType registers an arg type, it replaces the existing one
+// with the same name or Go type.
+func (r *Registry) RegisterArgType(t *ArgType) {
+	if t.Name == "" || t.Type == nil || t.Encode == nil || t.Decode == nil {
+		panic(fmt.Sprintf("codec: incomplete arg type %q", t.Name))
+	}
+	r.argTypes[t.Name] = t
+	r.argTypesByGo[t.Type] = t
+}
+
+// RegisterBuilderKind registers a builder kind, it replaces the existing
+// one with the same name or Go type. The name "segment" is reserved for
+// *sqls.Segment.
+func (r *Registry) RegisterBuilderKind(k *BuilderKind) {
+	if k.Name == "" || k.Type == nil || k.Encode == nil || k.Decode == nil {
+		panic(fmt.Sprintf("codec: incomplete builder kind %q", k.Name))
+	}
+	if k.Name == kindSegment || k.Type == reflect.TypeOf((*sqls.Segment)(nil)) {
+		panic("codec: builder kind of *sqls.Segment is builtin")
+	}
+	r.kinds[k.Name] = k
+	r.kindsByGo[k.Type] = k
+}
+
+// RegisterArgType registers an arg type to the DefaultRegistry.
+func RegisterArgType(t *ArgType) {
+	DefaultRegistry.RegisterArgType(t)
+}
+
+// RegisterBuilderKind registers a builder kind to the DefaultRegistry.
+func RegisterBuilderKind(k *BuilderKind) {
+	DefaultRegistry.RegisterBuilderKind(k)
+}
@@ -0,0 +1,224 @@
+package codec
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+func builtinArgTypes() []*ArgType {
+	return []*ArgType{
+		{
+			Name:   "bool",
+			Type:   reflect.TypeOf(false),
+			Encode: identity,
+			Decode: func(v any) (any, error) {
+				b, ok := v.(bool)
+				if !ok {
+					return nil, fmt.Errorf("want bool, got %T", v)
+				}
+				return b, nil
+			},
+		},
+		{
+			Name:   "string",
+			Type:   reflect.TypeOf(""),
+			Encode: identity,
+			Decode: func(v any) (any, error) {
+				s, ok := v.(string)
+				if !ok {
+					return nil, fmt.Errorf("want string, got %T", v)
+				}
+				return s, nil
+			},
+		},
+		intArgType("int", reflect.TypeOf(int(0))),
+		intArgType("int8", reflect.TypeOf(int8(0))),
+		intArgType("int16", reflect.TypeOf(int16(0))),
+		intArgType("int32", reflect.TypeOf(int32(0))),
+		intArgType("int64", reflect.TypeOf(int64(0))),
+		uintArgType("uint", reflect.TypeOf(uint(0))),
+		uintArgType("uint8", reflect.TypeOf(uint8(0))),
+		uintArgType("uint16", reflect.TypeOf(uint16(0))),
+		uintArgType("uint32", reflect.TypeOf(uint32(0))),
+		uintArgType("uint64", reflect.TypeOf(uint64(0))),
+		floatArgType("float32", reflect.TypeOf(float32(0))),
+		floatArgType("float64", reflect.TypeOf(float64(0))),
+		{
+			Name: "time",
+			Type: reflect.TypeOf(time.Time{}),
+			Encode: func(v any) (any, error) {
+				return v.(time.Time).Format(time.RFC3339Nano), nil
+			},
+			Decode: func(v any) (any, error) {
+				switch v := v.(type) {
+				case time.Time:
+					// YAML decodes timestamps
+					return v, nil
+				case string:
+					return time.Parse(time.RFC3339Nano, v)
+				}
+				return nil, fmt.Errorf("want RFC 3339 time string, got %T", v)
+			},
+		},
+		{
+			Name: "bytes",
+			Type: reflect.TypeOf([]byte(nil)),
+			Encode: func(v any) (any, error) {
+				return base64.StdEncoding.EncodeToString(v.([]byte)), nil
+			},
+			Decode: func(v any) (any, error) {
+				s, ok := v.(string)
+				if !ok {
+					return nil, fmt.Errorf("want base64 string, got %T", v)
+				}
+				return base64.StdEncoding.DecodeString(s)
+			},
+		},
+	}
+}
+
+func identity(v any) (any, error) {
+	return v, nil
+}
+
+func intArgType(name string, typ reflect.Type) *ArgType {
+	return &ArgType{
+		Name:   name,
+		Type:   typ,
+		Encode: identity,
+		Decode: func(v any) (any, error) {
+			n, err := toInt64(v)
+			if err != nil {
+				return nil, err
+			}
+			rv := reflect.New(typ).Elem()
+			if rv.OverflowInt(n) {
+				return nil, fmt.Errorf("%d overflows %s", n, name)
+			}
+			rv.SetInt(n)
+			return rv.Interface(), nil
+		},
+	}
+}
+
+func uintArgType(name string, typ reflect.Type) *ArgType {
+	return &ArgType{
+		Name:   name,
+		Type:   typ,
+		Encode: identity,
+		Decode: func(v any) (any, error) {
+			n, err := toUint64(v)
+			if err != nil {
+				return nil, err
+			}
+			rv := reflect.New(typ).Elem()
+			if rv.OverflowUint(n) {
+				return nil, fmt.Errorf("%d overflows %s", n, name)
+			}
+			rv.SetUint(n)
+			return rv.Interface(), nil
+		},
+	}
+}
+
+func floatArgType(name string, typ reflect.Type) *ArgType {
+	return &ArgType{
+		Name:   name,
+		Type:   typ,
+		Encode: identity,
+		Decode: func(v any) (any, error) {
+			f, err := toFloat64(v)
+			if err != nil {
+				return nil, err
+			}
+			rv := reflect.New(typ).Elem()
+			if rv.OverflowFloat(f) {
+				return nil, fmt.Errorf("%g overflows %s", f, name)
+			}
+			rv.SetFloat(f)
+			return rv.Interface(), nil
+		},
+	}
+}
+
+// decodeUntyped decodes the value without type, the numbers are decoded
+// as int64 if possible, otherwise float64.
+func decodeUntyped(v any) (any, error) {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	case int:
+		return int64(v), nil
+	case nil, bool, string, int64, uint64, float64, time.Time:
+		return v, nil
+	}
+	return nil, fmt.Errorf("untyped value of %T is not supported", v)
+}
+
+func toInt64(v any) (int64, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return strconv.ParseInt(string(v), 10, 64)
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("%d overflows int64", v)
+		}
+		return int64(v), nil
+	case float64:
+		// float64(math.MaxInt64) rounds up to 1<<63, which overflows int64
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= 1<<63 {
+			return 0, fmt.Errorf("%v is not an int64", v)
+		}
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("want integer, got %T", v)
+}
+
+func toUint64(v any) (uint64, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return strconv.ParseUint(string(v), 10, 64)
+	case uint64:
+		return v, nil
+	case string:
+		return strconv.ParseUint(v, 10, 64)
+	}
+	n, err := toInt64(v)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%d is not an unsigned integer", n)
+	}
+	return uint64(n), nil
+}
+
+func toFloat64(v any) (float64, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return v.Float64()
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	n, err := toInt64(v)
+	if err != nil {
+		return 0, fmt.Errorf("want number, got %T", v)
+	}
+	return float64(n), nil
+}
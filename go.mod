@@ -3,3 +3,5 @@ module github.com/qjebbs/go-sqls
 go 1.18
 
 require github.com/google/go-cmp v0.5.9
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -46,6 +46,17 @@ func TestBuildSegment(t *testing.T) {
 			want:     "WHERE t.id=?",
 			wantArgs: []any{nil},
 		},
+		{
+			segment: &sqls.Segment{
+				Raw: "SELECT #c1",
+				Columns: []*sqls.TableColumn{{
+					Raw:     "SUM(#c1) OVER (PARTITION BY #c2)",
+					Columns: []*sqls.TableColumn{alias.Column("amount"), table.Column("org_id")},
+				}},
+			},
+			want:     "SELECT SUM(t.amount) OVER (PARTITION BY table.org_id)",
+			wantArgs: []any{},
+		},
 		{
 			segment: &sqls.Segment{
 				Raw:     "WHERE #c1=$1",
@@ -0,0 +1,245 @@
+package sqlb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+var _ sqls.Builder = (*CompoundBuilder)(nil)
+
+// CompoundBuilder is the builder of compound queries, which combines the
+// queries with UNION, INTERSECT and EXCEPT, and applies ORDER BY, LIMIT and
+// OFFSET to the combined result. e.g.:
+//
+//	(SELECT ...) UNION ALL (SELECT ...) EXCEPT (SELECT ...) ORDER BY name ASC LIMIT 10
+//
+// The operators follow the precedence of SQL, where INTERSECT binds tighter
+// than UNION and EXCEPT. To change the evaluation order, use a nested
+// CompoundBuilder as an arm, which is parenthesized as well.
+//
+// SQLite doesn't allow parenthesized arms, so the arms are rendered as is,
+// except that a nested CompoundBuilder, or a *QueryBuilder with its own
+// WITH, ORDER BY or LIMIT, is wrapped as "SELECT * FROM (...)". Any other
+// sqls.Builder is rendered as is.
+type CompoundBuilder struct {
+	bindVarStyle syntax.BindVarStyle // the bindvar style
+	dialect      Dialect             // the SQL dialect
+
+	first  sqls.Builder   // the first arm
+	arms   []*compoundArm // the rest arms with operators
+	orders *sqls.Segment  // order by columns, joined with comma.
+	limit  int64          // limit count
+	offset int64          // offset count
+
+	bindPagination bool // render limit and offset as bindvars
+
+	errorList // errors during building
+}
+
+type compoundArm struct {
+	op      string
+	builder sqls.Builder
+}
+
+// NewCompoundBuilder returns a new CompoundBuilder with the first arm, the
+// builder can be *QueryBuilder or any other sqls.Builder.
+func NewCompoundBuilder(first sqls.Builder) *CompoundBuilder {
+	return &CompoundBuilder{
+		first: first,
+		orders: &sqls.Segment{
+			Prefix: "ORDER BY",
+			Raw:    "#join('#segment', ', ')",
+		},
+	}
+}
+
+// Union appends the arms with UNION.
+func (b *CompoundBuilder) Union(builders ...sqls.Builder) *CompoundBuilder {
+	return b.append("UNION", builders)
+}
+
+// UnionAll appends the arms with UNION ALL.
+func (b *CompoundBuilder) UnionAll(builders ...sqls.Builder) *CompoundBuilder {
+	return b.append("UNION ALL", builders)
+}
+
+// Intersect appends the arms with INTERSECT.
+func (b *CompoundBuilder) Intersect(builders ...sqls.Builder) *CompoundBuilder {
+	return b.append("INTERSECT", builders)
+}
+
+// IntersectAll appends the arms with INTERSECT ALL.
+func (b *CompoundBuilder) IntersectAll(builders ...sqls.Builder) *CompoundBuilder {
+	return b.append("INTERSECT ALL", builders)
+}
+
+// Except appends the arms with EXCEPT.
+func (b *CompoundBuilder) Except(builders ...sqls.Builder) *CompoundBuilder {
+	return b.append("EXCEPT", builders)
+}
+
+// ExceptAll appends the arms with EXCEPT ALL.
+func (b *CompoundBuilder) ExceptAll(builders ...sqls.Builder) *CompoundBuilder {
+	return b.append("EXCEPT ALL", builders)
+}
+
+func (b *CompoundBuilder) append(op string, builders []sqls.Builder) *CompoundBuilder {
+	for _, builder := range builders {
+		if builder == nil {
+			b.pushError(fmt.Errorf("%s: nil query", op))
+			continue
+		}
+		b.arms = append(b.arms, &compoundArm{op: op, builder: builder})
+	}
+	return b
+}
+
+// OrderBy set the sorting order of the combined result, the column is the
+// name or alias of an output column, since the tables of the arms are not
+// visible to the combined result.
+func (b *CompoundBuilder) OrderBy(column string, order Order) *CompoundBuilder {
+	if order > DescNullsLast {
+		b.pushError(fmt.Errorf("invalid order: %d", order))
+		return b
+	}
+	b.orders.AppendSegments(&sqls.Segment{
+		Raw: fmt.Sprintf("%s %s", column, orders[order]),
+	})
+	return b
+}
+
+// Limit set the limit of the combined result.
+func (b *CompoundBuilder) Limit(limit int64) *CompoundBuilder {
+	if limit > 0 {
+		b.limit = limit
+	}
+	return b
+}
+
+// Offset set the offset of the combined result.
+func (b *CompoundBuilder) Offset(offset int64) *CompoundBuilder {
+	if offset > 0 {
+		b.offset = offset
+	}
+	return b
+}
+
+// BindPagination renders the limit and offset as bindvars rather than
+// literals, see QueryBuilder.BindPagination().
+func (b *CompoundBuilder) BindPagination() *CompoundBuilder {
+	b.bindPagination = true
+	return b
+}
+
+// BindVar set the bindvar style.
+func (b *CompoundBuilder) BindVar(style syntax.BindVarStyle) *CompoundBuilder {
+	b.bindVarStyle = style
+	return b
+}
+
+// Dialect set the SQL dialect. The arms are not parenthesized for SQLite,
+// which doesn't allow it, so that the arms should not have ORDER BY or LIMIT.
+func (b *CompoundBuilder) Dialect(d Dialect) *CompoundBuilder {
+	b.dialect = d
+	return b
+}
+
+// Build builds the query.
+func (b *CompoundBuilder) Build() (query string, args []any, err error) {
+	args = make([]any, 0)
+	ctx := sqls.NewContext(&args)
+	ctx.BindVarStyle = b.bindVarStyle
+	query, err = b.buildInternal(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	return query, args, nil
+}
+
+// BuildContext builds the query with the context.
+func (b *CompoundBuilder) BuildContext(ctx *sqls.Context) (query string, err error) {
+	return b.buildInternal(ctx)
+}
+
+func (b *CompoundBuilder) buildInternal(ctx *sqls.Context) (string, error) {
+	if b == nil {
+		return "", nil
+	}
+	if err := b.anyError(); err != nil {
+		return "", err
+	}
+	if b.first == nil {
+		return "", fmt.Errorf("no query to combine")
+	}
+	if len(b.arms) == 0 {
+		return "", fmt.Errorf("no query to combine with, call Union(), Intersect() or Except()")
+	}
+	clauses := make([]string, 0, 2*len(b.arms)+4)
+	first, err := b.buildArm(ctx, b.first, 1)
+	if err != nil {
+		return "", err
+	}
+	clauses = append(clauses, first)
+	for i, arm := range b.arms {
+		query, err := b.buildArm(ctx, arm.builder, i+2)
+		if err != nil {
+			return "", err
+		}
+		clauses = append(clauses, arm.op, query)
+	}
+	order, err := b.orders.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if order != "" {
+		clauses = append(clauses, order)
+	}
+	pagination, err := paginationSegment(b.dialect, b.limit, b.offset, order != "", b.bindPagination)
+	if err != nil {
+		return "", err
+	}
+	page, err := pagination.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if page != "" {
+		clauses = append(clauses, page)
+	}
+	return strings.Join(clauses, " "), nil
+}
+
+func (b *CompoundBuilder) buildArm(ctx *sqls.Context, builder sqls.Builder, index int) (string, error) {
+	query, err := builder.BuildContext(ctx)
+	if err != nil {
+		return "", fmt.Errorf("build query %d: %w", index, err)
+	}
+	if query == "" {
+		return "", fmt.Errorf("build query %d: empty query", index)
+	}
+	if b.dialect == DialectSQLite {
+		if !isCompoundArmSafe(builder) {
+			// SQLite doesn't allow parenthesized arms
+			return "SELECT * FROM (" + query + ")", nil
+		}
+		return query, nil
+	}
+	return "(" + query + ")", nil
+}
+
+// isCompoundArmSafe tells if the query can be used as a compound arm without
+// parentheses, i.e., it has no WITH, ORDER BY, LIMIT / OFFSET or compound
+// operators of its own.
+func isCompoundArmSafe(builder sqls.Builder) bool {
+	switch q := builder.(type) {
+	case *CompoundBuilder:
+		return false
+	case *QueryBuilder:
+		return len(q.ctes) == 0 && len(q.orders.Segments) == 0 && len(q.sorts) == 0 &&
+			q.limit == 0 && q.offset == 0 && len(q.unions) == 0 && q.keyset == nil
+	default:
+		return true
+	}
+}
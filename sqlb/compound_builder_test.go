@@ -0,0 +1,139 @@
+package sqlb_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qjebbs/go-sqls/sqlb"
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+func TestCompoundBuilder(t *testing.T) {
+	var (
+		users   = sqlb.NewTable("users", "u")
+		admins  = sqlb.NewTable("admins", "a")
+		banned  = sqlb.NewTable("banned", "b")
+		selectf = func(t sqlb.Table, arg any) *sqlb.QueryBuilder {
+			return sqlb.NewQueryBuilder().
+				Select(t.Columns("id", "name")...).
+				From(t).
+				Where2(t.Column("org"), "=", arg)
+		}
+	)
+	testCases := []struct {
+		name      string
+		builder   *sqlb.CompoundBuilder
+		wantQuery string
+		wantArgs  []any
+	}{
+		{
+			name: "mixed operators with order and limit",
+			builder: sqlb.NewCompoundBuilder(selectf(users, 1)).
+				BindVar(syntax.Dollar).
+				UnionAll(selectf(admins, 2)).
+				Except(selectf(banned, 3)).
+				OrderBy("name", sqlb.Asc).
+				Limit(10).
+				Offset(20),
+			wantQuery: "(SELECT u.id, u.name FROM users AS u WHERE u.org=$1) UNION ALL (SELECT a.id, a.name FROM admins AS a WHERE a.org=$2) EXCEPT (SELECT b.id, b.name FROM banned AS b WHERE b.org=$3) ORDER BY name ASC LIMIT 10 OFFSET 20",
+			wantArgs:  []any{1, 2, 3},
+		},
+		{
+			name: "nested",
+			builder: sqlb.NewCompoundBuilder(
+				sqlb.NewCompoundBuilder(selectf(users, 1)).Union(selectf(admins, 2)),
+			).
+				BindVar(syntax.Question).
+				Intersect(selectf(banned, 3)),
+			wantQuery: "((SELECT u.id, u.name FROM users AS u WHERE u.org=?) UNION (SELECT a.id, a.name FROM admins AS a WHERE a.org=?)) INTERSECT (SELECT b.id, b.name FROM banned AS b WHERE b.org=?)",
+			wantArgs:  []any{1, 2, 3},
+		},
+		{
+			name: "sqlite",
+			builder: sqlb.NewCompoundBuilder(selectf(users, 1)).
+				BindVar(syntax.Question).
+				Dialect(sqlb.DialectSQLite).
+				Union(selectf(admins, 2)).
+				OrderBy("id", sqlb.Desc),
+			wantQuery: "SELECT u.id, u.name FROM users AS u WHERE u.org=? UNION SELECT a.id, a.name FROM admins AS a WHERE a.org=? ORDER BY id DESC",
+			wantArgs:  []any{1, 2},
+		},
+		{
+			name: "sqlite nested and limited arms",
+			builder: sqlb.NewCompoundBuilder(selectf(users, 1).Limit(5)).
+				BindVar(syntax.Question).
+				Dialect(sqlb.DialectSQLite).
+				Except(
+					sqlb.NewCompoundBuilder(selectf(admins, 2)).
+						Dialect(sqlb.DialectSQLite).
+						Union(selectf(banned, 3)),
+				),
+			wantQuery: "SELECT * FROM (SELECT u.id, u.name FROM users AS u WHERE u.org=? LIMIT 5) EXCEPT SELECT * FROM (SELECT a.id, a.name FROM admins AS a WHERE a.org=? UNION SELECT b.id, b.name FROM banned AS b WHERE b.org=?)",
+			wantArgs:  []any{1, 2, 3},
+		},
+		{
+			name: "sql server pagination bindvars",
+			builder: sqlb.NewCompoundBuilder(selectf(users, 1)).
+				BindVar(syntax.Question).
+				Dialect(sqlb.DialectSQLServer).
+				Union(selectf(admins, 2)).
+				OrderBy("id", sqlb.Desc).
+				Limit(10).
+				BindPagination(),
+			wantQuery: "(SELECT u.id, u.name FROM users AS u WHERE u.org=?) UNION (SELECT a.id, a.name FROM admins AS a WHERE a.org=?) ORDER BY id DESC OFFSET ? ROWS FETCH NEXT ? ROWS ONLY",
+			wantArgs:  []any{1, 2, int64(0), int64(10)},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotQuery, gotArgs, err := tc.builder.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotQuery != tc.wantQuery {
+				t.Errorf("got:\n%s\nwant:\n%s", gotQuery, tc.wantQuery)
+			}
+			if !reflect.DeepEqual(gotArgs, tc.wantArgs) {
+				t.Errorf("got:\n%#v\nwant:\n%#v", gotArgs, tc.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCompoundBuilderErrors(t *testing.T) {
+	users := sqlb.NewTable("users", "u")
+	q := sqlb.NewQueryBuilder().Select(users.Column("id")).From(users)
+	testCases := []struct {
+		name    string
+		builder *sqlb.CompoundBuilder
+		wantErr string
+	}{
+		{
+			name:    "single arm",
+			builder: sqlb.NewCompoundBuilder(q),
+			wantErr: "no query to combine with",
+		},
+		{
+			name:    "arm error",
+			builder: sqlb.NewCompoundBuilder(q).Union(sqlb.NewQueryBuilder().From(users)),
+			wantErr: "build query 2: no columns selected",
+		},
+		{
+			name:    "invalid order",
+			builder: sqlb.NewCompoundBuilder(q).Union(q).OrderBy("id", sqlb.Order(100)),
+			wantErr: "invalid order: 100",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := tc.builder.Build()
+			if err == nil {
+				t.Fatal("want error, got nil")
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %q, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package sqlb
+
+import "github.com/qjebbs/go-sqls"
+
+// CondGroup is a group of conditions joined with AND or OR, which can be
+// nested to any depth. The empty groups are dropped. e.g.:
+//
+//	b.WhereAny(func(g *sqlb.CondGroup) {
+//		g.Where2(users.Column("role"), "=", "admin").
+//			WhereAll(func(g *sqlb.CondGroup) {
+//				g.Where2(users.Column("role"), "=", "editor").
+//					Where2(users.Column("verified"), "=", true)
+//			})
+//	})
+//	// WHERE (u.role=$1 OR (u.role=$2 AND u.verified=$3))
+type CondGroup struct {
+	sep        string // the separator of the conditions
+	not        bool   // negate the group
+	conditions []*sqls.Segment
+
+	errorList // errors reported to the builder
+}
+
+func newCondGroup(sep string, not bool, fn func(g *CondGroup)) *CondGroup {
+	g := &CondGroup{sep: sep, not: not}
+	fn(g)
+	return g
+}
+
+// Where add a condition to the group, see QueryBuilder.Where().
+func (g *CondGroup) Where(s *sqls.Segment) *CondGroup {
+	if s == nil {
+		return g
+	}
+	g.conditions = append(g.conditions, s)
+	return g
+}
+
+// Where2 adds a simple condition to the group, see QueryBuilder.Where2().
+func (g *CondGroup) Where2(column *sqls.TableColumn, op string, arg any) *CondGroup {
+	s, err := where2(column, op, arg)
+	if err != nil {
+		g.pushError(err)
+		return g
+	}
+	return g.Where(s)
+}
+
+// WhereIn adds a IN condition to the group like `t.id IN (1,2,3)`
+func (g *CondGroup) WhereIn(column *sqls.TableColumn, list any) *CondGroup {
+	return g.Where(whereIn(column, list))
+}
+
+// WhereNotIn adds a NOT IN condition to the group like `t.id NOT IN (1,2,3)`
+func (g *CondGroup) WhereNotIn(column *sqls.TableColumn, list any) *CondGroup {
+	return g.Where(whereNotIn(column, list))
+}
+
+// WhereBetween adds a BETWEEN condition to the group, see QueryBuilder.WhereBetween().
+func (g *CondGroup) WhereBetween(column *sqls.TableColumn, from, to any) *CondGroup {
+	return g.Where(whereBetween(column, from, to))
+}
+
+// WhereNull adds a IS NULL condition to the group.
+func (g *CondGroup) WhereNull(column *sqls.TableColumn) *CondGroup {
+	return g.Where(whereNull(column, false))
+}
+
+// WhereNotNull adds a IS NOT NULL condition to the group.
+func (g *CondGroup) WhereNotNull(column *sqls.TableColumn) *CondGroup {
+	return g.Where(whereNull(column, true))
+}
+
+// WhereLike adds a LIKE condition to the group, see QueryBuilder.WhereLike().
+func (g *CondGroup) WhereLike(column *sqls.TableColumn, value string, mode LikeMode) *CondGroup {
+	s, err := whereLike(column, value, mode)
+	if err != nil {
+		g.pushError(err)
+		return g
+	}
+	return g.Where(s)
+}
+
+// WhereExists adds a EXISTS condition to the group, see QueryBuilder.WhereExists().
+func (g *CondGroup) WhereExists(builder sqls.Builder) *CondGroup {
+	return g.Where(whereExists(builder, false))
+}
+
+// WhereNotExists adds a NOT EXISTS condition to the group, see QueryBuilder.WhereExists().
+func (g *CondGroup) WhereNotExists(builder sqls.Builder) *CondGroup {
+	return g.Where(whereExists(builder, true))
+}
+
+// WhereInQuery adds a IN condition of the subquery to the group, see QueryBuilder.WhereExists().
+func (g *CondGroup) WhereInQuery(column *sqls.TableColumn, builder sqls.Builder) *CondGroup {
+	return g.Where(whereInQuery(column, builder))
+}
+
+// WhereAny adds a nested group, whose conditions are joined with OR.
+func (g *CondGroup) WhereAny(fn func(g *CondGroup)) *CondGroup {
+	return g.whereGroup(newCondGroup(" OR ", false, fn))
+}
+
+// WhereAll adds a nested group, whose conditions are joined with AND.
+func (g *CondGroup) WhereAll(fn func(g *CondGroup)) *CondGroup {
+	return g.whereGroup(newCondGroup(" AND ", false, fn))
+}
+
+// WhereNot adds a nested group, whose conditions are joined with AND and
+// negated with NOT.
+func (g *CondGroup) WhereNot(fn func(g *CondGroup)) *CondGroup {
+	return g.whereGroup(newCondGroup(" AND ", true, fn))
+}
+
+func (g *CondGroup) whereGroup(nested *CondGroup) *CondGroup {
+	g.errors = append(g.errors, nested.errors...)
+	return g.Where(nested.segment())
+}
+
+// segment returns the condition segment of the group, or nil if empty.
+func (g *CondGroup) segment() *sqls.Segment {
+	switch {
+	case len(g.conditions) == 0:
+		return nil
+	case len(g.conditions) == 1 && !g.not:
+		return g.conditions[0]
+	}
+	s := &sqls.Segment{
+		Raw:      "(#join('#segment', '" + g.sep + "'))",
+		Segments: g.conditions,
+	}
+	if g.not {
+		s.Raw = "NOT " + s.Raw
+	}
+	return s
+}
@@ -0,0 +1,283 @@
+package sqlb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+var _ sqls.Builder = (*DeleteBuilder)(nil)
+
+// DeleteBuilder is the SQL DELETE statement builder.
+//
+// The joined tables are rendered according to the dialect:
+//
+//   - PostgreSQL: DELETE FROM t USING t2 JOIN t3 ON ... WHERE ...
+//   - MySQL, SQL Server: DELETE t FROM t JOIN t2 ON ... WHERE ...
+//   - SQLite: joins are not supported
+//
+// The optional joins are trimmed if no WHERE, ORDER BY or RETURNING
+// references them.
+type DeleteBuilder struct {
+	bindVarStyle syntax.BindVarStyle // the bindvar style
+	dialect      Dialect             // the SQL dialect
+
+	fromTables // the target and join tables
+
+	conditions *sqls.Segment // where conditions, joined with AND.
+	orders     *sqls.Segment // order by columns, joined with comma.
+	limit      int64         // limit count
+	returning  *sqls.Segment // returning columns
+
+	allowFullTable bool // allow DELETE without WHERE
+
+	errorList // errors during building
+}
+
+// NewDeleteBuilder returns a new DeleteBuilder.
+func NewDeleteBuilder() *DeleteBuilder {
+	return &DeleteBuilder{
+		fromTables: newFromTables(),
+		conditions: &sqls.Segment{
+			Prefix: "WHERE",
+			Raw:    "#join('#segment', ' AND ')",
+		},
+		orders: &sqls.Segment{
+			Prefix: "ORDER BY",
+			Raw:    "#join('#segment', ', ')",
+		},
+		returning: &sqls.Segment{
+			Prefix: "RETURNING",
+			Raw:    "#join('#column', ', ')",
+		},
+	}
+}
+
+// From set the table to delete from.
+func (b *DeleteBuilder) From(t Table) *DeleteBuilder {
+	if err := b.setFrom(t); err != nil {
+		b.pushError(fmt.Errorf("delete table is empty"))
+	}
+	return b
+}
+
+// Using append a table, whose relation to the deleted table is specified
+// by the WHERE conditions.
+func (b *DeleteBuilder) Using(t Table) *DeleteBuilder {
+	return b.join("CROSS JOIN", t, nil, false)
+}
+
+// InnerJoin append a inner join table.
+func (b *DeleteBuilder) InnerJoin(t Table, on *sqls.Segment) *DeleteBuilder {
+	return b.join("INNER JOIN", t, on, false)
+}
+
+// LeftJoin append a left join table.
+func (b *DeleteBuilder) LeftJoin(t Table, on *sqls.Segment) *DeleteBuilder {
+	return b.join("LEFT JOIN", t, on, false)
+}
+
+// LeftJoinOptional append a left join table, and mark it as optional, which
+// is trimmed if no WHERE, ORDER BY or RETURNING references it.
+//
+// Make sure all columns referenced in the query are reflected in
+// *sqls.Segment.Columns, so that the *DeleteBuilder can calculate the
+// dependency correctly.
+func (b *DeleteBuilder) LeftJoinOptional(t Table, on *sqls.Segment) *DeleteBuilder {
+	return b.join("LEFT JOIN", t, on, true)
+}
+
+func (b *DeleteBuilder) join(joinStr string, t Table, on *sqls.Segment, optional bool) *DeleteBuilder {
+	if err := b.fromTables.join(joinStr, t, on, optional, false); err != nil {
+		b.pushError(err)
+	}
+	return b
+}
+
+// Where add a condition.  e.g.:
+//
+//	b.Where(&sqls.Segment{
+//		Raw: "#c1 = $1",
+//		Columns: t.Columns("id"),
+//		Args: []any{1},
+//	})
+func (b *DeleteBuilder) Where(s *sqls.Segment) *DeleteBuilder {
+	if s == nil {
+		return b
+	}
+	b.conditions.AppendSegments(s)
+	return b
+}
+
+// Where2 is a helper func similar to Where(), which adds a simple where condition. e.g.:
+//
+//	b.Where2(column, "=", 1)
+func (b *DeleteBuilder) Where2(column *sqls.TableColumn, op string, arg any) *DeleteBuilder {
+	s, err := where2(column, op, arg)
+	if err != nil {
+		b.pushError(err)
+		return b
+	}
+	return b.Where(s)
+}
+
+// WhereIn adds a where IN condition like `t.id IN (1,2,3)`
+func (b *DeleteBuilder) WhereIn(column *sqls.TableColumn, list any) *DeleteBuilder {
+	return b.Where(whereIn(column, list))
+}
+
+// WhereNotIn adds a where NOT IN condition like `t.id NOT IN (1,2,3)`
+func (b *DeleteBuilder) WhereNotIn(column *sqls.TableColumn, list any) *DeleteBuilder {
+	return b.Where(whereNotIn(column, list))
+}
+
+// OrderBy set the sorting order, which is supported by MySQL and SQLite,
+// and only for the DELETE without joins.
+func (b *DeleteBuilder) OrderBy(column *sqls.TableColumn, order Order) *DeleteBuilder {
+	if order > DescNullsLast {
+		b.pushError(fmt.Errorf("invalid order: %d", order))
+		return b
+	}
+	b.orders.AppendSegments(&sqls.Segment{
+		Raw:     "#c1 " + orders[order],
+		Columns: []*sqls.TableColumn{column},
+	})
+	return b
+}
+
+// Limit set the limit, which is supported by MySQL and SQLite, and only for
+// the DELETE without joins.
+func (b *DeleteBuilder) Limit(limit int64) *DeleteBuilder {
+	if limit > 0 {
+		b.limit = limit
+	}
+	return b
+}
+
+// Returning set the RETURNING columns, which is supported by PostgreSQL
+// and SQLite.
+func (b *DeleteBuilder) Returning(columns ...*sqls.TableColumn) *DeleteBuilder {
+	b.returning.WithColumns(columns...)
+	return b
+}
+
+// AllowFullTable allows to build the DELETE without WHERE conditions,
+// which deletes all the rows of the table.
+func (b *DeleteBuilder) AllowFullTable() *DeleteBuilder {
+	b.allowFullTable = true
+	return b
+}
+
+// BindVar set the bindvar style.
+func (b *DeleteBuilder) BindVar(style syntax.BindVarStyle) *DeleteBuilder {
+	b.bindVarStyle = style
+	return b
+}
+
+// Dialect set the SQL dialect, which decides how the joins are rendered.
+func (b *DeleteBuilder) Dialect(d Dialect) *DeleteBuilder {
+	b.dialect = d
+	return b
+}
+
+// Build builds the query.
+func (b *DeleteBuilder) Build() (query string, args []any, err error) {
+	args = make([]any, 0)
+	ctx := sqls.NewContext(&args)
+	ctx.BindVarStyle = b.bindVarStyle
+	query, err = b.buildInternal(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	return query, args, nil
+}
+
+// BuildContext builds the query with the context.
+func (b *DeleteBuilder) BuildContext(ctx *sqls.Context) (query string, err error) {
+	return b.buildInternal(ctx)
+}
+
+func (b *DeleteBuilder) buildInternal(ctx *sqls.Context) (string, error) {
+	if b == nil {
+		return "", nil
+	}
+	if err := b.anyError(); err != nil {
+		return "", err
+	}
+	if len(b.tables) == 0 || b.tables[0].Name == "" {
+		return "", fmt.Errorf("no table to delete from")
+	}
+	if len(b.conditions.Segments) == 0 && !b.allowFullTable {
+		return "", fmt.Errorf("DELETE without WHERE is not allowed, call AllowFullTable() to confirm")
+	}
+	if len(b.returning.Columns) > 0 && (b.dialect == DialectMySQL || b.dialect == DialectSQLServer) {
+		return "", fmt.Errorf("RETURNING is not supported by %s", b.dialect)
+	}
+	segments := []*sqls.Segment{b.conditions, b.orders, b.returning}
+	if err := b.checkColumns(append(segments, b.joinConditions()...)...); err != nil {
+		return "", err
+	}
+	dep, err := b.dependencies(b.conditions, b.orders, b.returning)
+	if err != nil {
+		return "", err
+	}
+	joins := b.requiredJoins(dep)
+	if len(b.orders.Segments) > 0 || b.limit > 0 {
+		if b.dialect != DialectMySQL && b.dialect != DialectSQLite {
+			return "", fmt.Errorf("ORDER BY and LIMIT are not supported by %s", b.dialect)
+		}
+		if len(joins) > 0 {
+			return "", fmt.Errorf("ORDER BY and LIMIT are not supported by multiple-table DELETE")
+		}
+	}
+	target := b.tables[0]
+	clauses := make([]string, 0)
+	conditions := b.conditions
+	switch {
+	case len(joins) == 0:
+		clauses = append(clauses, "DELETE FROM "+tableAndAlias(target, b.dialect))
+	case b.dialect == DialectPostgreSQL:
+		clauses = append(clauses, "DELETE FROM "+tableAndAlias(target, b.dialect))
+		var using string
+		using, conditions, err = b.buildJoinsAsFrom(ctx, "USING", b.dialect, joins, conditions)
+		if err != nil {
+			return "", err
+		}
+		clauses = append(clauses, using)
+	case b.dialect == DialectMySQL, b.dialect == DialectSQLServer:
+		tables, err := b.buildTables(ctx, target, joins)
+		if err != nil {
+			return "", err
+		}
+		clauses = append(clauses, fmt.Sprintf("DELETE %s FROM %s", target.AppliedName(), tables))
+	default:
+		return "", fmt.Errorf("DELETE with joins is not supported by %s", b.dialect)
+	}
+	where, err := conditions.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if where != "" {
+		clauses = append(clauses, where)
+	}
+	order, err := b.orders.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if order != "" {
+		clauses = append(clauses, order)
+	}
+	if b.limit > 0 {
+		clauses = append(clauses, fmt.Sprintf(`LIMIT %d`, b.limit))
+	}
+	returning, err := b.returning.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if returning != "" {
+		clauses = append(clauses, returning)
+	}
+	return strings.Join(clauses, " "), nil
+}
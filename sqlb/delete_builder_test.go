@@ -0,0 +1,192 @@
+package sqlb_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/sqlb"
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+func TestDeleteBuilder(t *testing.T) {
+	var (
+		users    = sqlb.NewTable("users", "u")
+		sessions = sqlb.NewTable("sessions", "s")
+		devices  = sqlb.NewTable("devices", "d")
+	)
+	testCases := []struct {
+		name      string
+		builder   *sqlb.DeleteBuilder
+		wantQuery string
+		wantArgs  []any
+	}{
+		{
+			name: "postgres",
+			builder: sqlb.NewDeleteBuilder().
+				BindVar(syntax.Dollar).
+				Dialect(sqlb.DialectPostgreSQL).
+				From(sessions).
+				InnerJoin(users, eq(users.Column("id"), sessions.Column("user_id"))).
+				LeftJoinOptional(devices, eq(devices.Column("id"), sessions.Column("device_id"))). // not referenced, should be trimmed
+				Where2(users.Column("banned"), "=", true).
+				Returning(sessions.Column("id")),
+			wantQuery: "DELETE FROM sessions AS s USING users AS u WHERE u.id=s.user_id AND u.banned=$1 RETURNING s.id",
+			wantArgs:  []any{true},
+		},
+		{
+			name: "mysql",
+			builder: sqlb.NewDeleteBuilder().
+				BindVar(syntax.Dollar).
+				Dialect(sqlb.DialectMySQL).
+				From(sessions).
+				InnerJoin(users, eq(users.Column("id"), sessions.Column("user_id"))).
+				LeftJoinOptional(devices, eq(devices.Column("id"), sessions.Column("device_id"))). // not referenced, should be trimmed
+				Where2(users.Column("banned"), "=", true),
+			wantQuery: "DELETE s FROM sessions AS s INNER JOIN users AS u ON u.id=s.user_id WHERE u.banned=$1",
+			wantArgs:  []any{true},
+		},
+		{
+			name: "sqlserver",
+			builder: sqlb.NewDeleteBuilder().
+				BindVar(syntax.Dollar).
+				Dialect(sqlb.DialectSQLServer).
+				From(sessions).
+				InnerJoin(users, eq(users.Column("id"), sessions.Column("user_id"))).
+				LeftJoinOptional(devices, eq(devices.Column("id"), sessions.Column("device_id"))). // not referenced, should be trimmed
+				Where2(users.Column("banned"), "=", true),
+			wantQuery: "DELETE s FROM sessions AS s INNER JOIN users AS u ON u.id=s.user_id WHERE u.banned=$1",
+			wantArgs:  []any{true},
+		},
+		{
+			name: "postgres later join references target",
+			builder: sqlb.NewDeleteBuilder().
+				BindVar(syntax.Dollar).
+				From(sessions).
+				InnerJoin(users, eq(users.Column("id"), sessions.Column("user_id"))).
+				InnerJoin(devices, &sqls.Segment{
+					Raw:     "#c1=#c2 AND #c3=#c4",
+					Columns: []*sqls.TableColumn{devices.Column("id"), sessions.Column("device_id"), devices.Column("owner_id"), users.Column("id")},
+				}).
+				Where2(devices.Column("lost"), "=", true),
+			wantQuery: "DELETE FROM sessions AS s USING users AS u CROSS JOIN devices AS d WHERE u.id=s.user_id AND d.id=s.device_id AND d.owner_id=u.id AND d.lost=$1",
+			wantArgs:  []any{true},
+		},
+		{
+			name: "postgres using",
+			builder: sqlb.NewDeleteBuilder().
+				BindVar(syntax.Dollar).
+				From(sessions).
+				Using(users).
+				Where(eq(users.Column("id"), sessions.Column("user_id"))),
+			wantQuery: "DELETE FROM sessions AS s USING users AS u WHERE u.id=s.user_id",
+			wantArgs:  []any{},
+		},
+		{
+			name: "mysql order by and limit",
+			builder: sqlb.NewDeleteBuilder().
+				BindVar(syntax.Question).
+				Dialect(sqlb.DialectMySQL).
+				From(sessions).
+				Where2(sessions.Column("expired"), "=", true).
+				OrderBy(sessions.Column("created_at"), sqlb.Asc).
+				Limit(100),
+			wantQuery: "DELETE FROM sessions AS s WHERE s.expired=? ORDER BY s.created_at ASC LIMIT 100",
+			wantArgs:  []any{true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotQuery, gotArgs, err := tc.builder.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotQuery != tc.wantQuery {
+				t.Errorf("got:\n%s\nwant:\n%s", gotQuery, tc.wantQuery)
+			}
+			if !reflect.DeepEqual(gotArgs, tc.wantArgs) {
+				t.Errorf("got:\n%#v\nwant:\n%#v", gotArgs, tc.wantArgs)
+			}
+		})
+	}
+}
+
+func TestDeleteBuilderErrors(t *testing.T) {
+	var (
+		users    = sqlb.NewTable("users", "u")
+		sessions = sqlb.NewTable("sessions", "s")
+		typed    = sessions.WithSchema(sqlb.NewSchema(
+			&sqlb.ColumnDef{Name: "id", Type: reflect.TypeOf(int64(0)), PrimaryKey: true},
+			&sqlb.ColumnDef{Name: "user_id", Type: reflect.TypeOf(int64(0))},
+		))
+	)
+	joinUsers := eq(users.Column("id"), sessions.Column("user_id"))
+	testCases := []struct {
+		name    string
+		builder *sqlb.DeleteBuilder
+		wantErr string
+	}{
+		{
+			name:    "no where",
+			builder: sqlb.NewDeleteBuilder().From(sessions),
+			wantErr: "DELETE without WHERE is not allowed",
+		},
+		{
+			name:    "postgres limit",
+			builder: sqlb.NewDeleteBuilder().From(sessions).Limit(1).AllowFullTable(),
+			wantErr: "ORDER BY and LIMIT are not supported by PostgreSQL",
+		},
+		{
+			name: "mysql limit with joins",
+			builder: sqlb.NewDeleteBuilder().
+				Dialect(sqlb.DialectMySQL).
+				From(sessions).
+				InnerJoin(users, joinUsers).
+				Limit(1).
+				AllowFullTable(),
+			wantErr: "ORDER BY and LIMIT are not supported by multiple-table DELETE",
+		},
+		{
+			name:    "undeclared column",
+			builder: sqlb.NewDeleteBuilder().From(typed).Where2(typed.Column("uid"), "=", 1),
+			wantErr: "column 'uid' is not declared in table 'sessions'",
+		},
+		{
+			name: "undeclared join column",
+			builder: sqlb.NewDeleteBuilder().
+				From(typed).
+				InnerJoin(users, eq(users.Column("id"), typed.Column("userid"))).
+				Where2(users.Column("banned"), "=", true),
+			wantErr: "column 'userid' is not declared in table 'sessions'",
+		},
+		{
+			name: "sqlite joins",
+			builder: sqlb.NewDeleteBuilder().
+				Dialect(sqlb.DialectSQLite).
+				From(sessions).
+				InnerJoin(users, joinUsers).
+				AllowFullTable(),
+			wantErr: "DELETE with joins is not supported by SQLite",
+		},
+		{
+			name: "collected errors",
+			builder: sqlb.NewDeleteBuilder().
+				From(sqlb.Table{}).
+				OrderBy(sessions.Column("id"), sqlb.Order(100)).
+				AllowFullTable(),
+			wantErr: "collected errors: \n - delete table is empty\n - invalid order: 100\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := tc.builder.Build()
+			if err == nil {
+				t.Fatal("want error, got nil")
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %q, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package sqlb
+
+// Dialect is the SQL dialect, which decides how the dialect-specific
+// clauses are rendered, e.g. the upsert of InsertBuilder.
+//
+// The zero value is DialectPostgreSQL.
+type Dialect int
+
+// Supported dialects.
+const (
+	DialectPostgreSQL Dialect = iota
+	DialectMySQL
+	DialectSQLite
+	DialectSQLServer
+	DialectOracle
+)
+
+// String implements fmt.Stringer.
+func (d Dialect) String() string {
+	switch d {
+	case DialectPostgreSQL:
+		return "PostgreSQL"
+	case DialectMySQL:
+		return "MySQL"
+	case DialectSQLite:
+		return "SQLite"
+	case DialectSQLServer:
+		return "SQL Server"
+	case DialectOracle:
+		return "Oracle"
+	default:
+		return "Unknown"
+	}
+}
@@ -0,0 +1,30 @@
+package sqlb
+
+import (
+	"errors"
+	"strings"
+)
+
+// errorList collects the errors during building, which are reported
+// together on Build().
+type errorList struct {
+	errors []error
+}
+
+func (l *errorList) pushError(err error) {
+	l.errors = append(l.errors, err)
+}
+
+func (l *errorList) anyError() error {
+	if len(l.errors) == 0 {
+		return nil
+	}
+	sb := new(strings.Builder)
+	sb.WriteString("collected errors: \n")
+	for _, err := range l.errors {
+		sb.WriteString(" - ")
+		sb.WriteString(err.Error())
+		sb.WriteRune('\n')
+	}
+	return errors.New(sb.String())
+}
@@ -113,7 +113,7 @@ func ExampleQueryBuilder_With() {
 	fmt.Println(query)
 	fmt.Println(args)
 	// Output:
-	// With bar_type_1 AS (SELECT * FROM bar AS b WHERE b.type=$1) SELECT f.*, b1.* FROM foo AS f LEFT JOIN bar_type_1 AS b1 ON b1.foo_id=f.id
+	// WITH bar_type_1 AS (SELECT * FROM bar AS b WHERE b.type=$1) SELECT f.*, b1.* FROM foo AS f LEFT JOIN bar_type_1 AS b1 ON b1.foo_id=f.id
 	// [1]
 }
 
@@ -142,3 +142,164 @@ func ExampleQueryBuilder_Union() {
 	// SELECT f.* FROM foo AS f WHERE f.id = $1 UNION (SELECT f.* FROM foo AS f WHERE f.id IN ($2, $3, $4))
 	// [1 2 3 4]
 }
+
+func ExampleInsertBuilder() {
+	users := sqlb.NewTable("users", "")
+	query, args, err := sqlb.NewInsertBuilder().
+		BindVar(syntax.Dollar).
+		Into(users).
+		Columns("name", "created_at").
+		Values("alice", &sqls.Segment{Raw: "NOW()"}).
+		Values("bob", &sqls.Segment{Raw: "NOW()"}).
+		Returning(users.Column("id")).
+		Build()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(query)
+	fmt.Println(args)
+	// Output:
+	// INSERT INTO users (name, created_at) VALUES ($1, NOW()), ($2, NOW()) RETURNING users.id
+	// [alice bob]
+}
+
+func ExampleInsertBuilder_OnConflict() {
+	users := sqlb.NewTable("users", "")
+	b := sqlb.NewInsertBuilder().
+		BindVar(syntax.Dollar).
+		Into(users).
+		Columns("email", "name").
+		Values("alice@example.com", "alice")
+	b.OnConflict("email").DoUpdateAllExcept("email")
+	query, args, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(query)
+	fmt.Println(args)
+	// Output:
+	// INSERT INTO users (email, name) VALUES ($1, $2) ON CONFLICT (email) DO UPDATE SET name = EXCLUDED.name
+	// [alice@example.com alice]
+}
+
+func ExampleUpdateBuilder() {
+	var (
+		users  = sqlb.NewTable("users", "u")
+		orders = sqlb.NewTable("orders", "o")
+	)
+	query, args, err := sqlb.NewUpdateBuilder().
+		BindVar(syntax.Dollar).
+		Table(users).
+		InnerJoin(orders, &sqls.Segment{
+			Raw: "#c1=#c2",
+			Columns: []*sqls.TableColumn{
+				orders.Column("user_id"),
+				users.Column("id"),
+			},
+		}).
+		Set("vip", true).
+		Where2(orders.Column("amount"), ">", 100).
+		Build()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(query)
+	fmt.Println(args)
+	// Output:
+	// UPDATE users AS u SET vip = $1 FROM orders AS o WHERE o.user_id=u.id AND o.amount>$2
+	// [true 100]
+}
+
+func ExampleDeleteBuilder() {
+	var (
+		users    = sqlb.NewTable("users", "u")
+		sessions = sqlb.NewTable("sessions", "s")
+	)
+	query, args, err := sqlb.NewDeleteBuilder().
+		BindVar(syntax.Question).
+		Dialect(sqlb.DialectMySQL).
+		From(sessions).
+		InnerJoin(users, &sqls.Segment{
+			Raw: "#c1=#c2",
+			Columns: []*sqls.TableColumn{
+				users.Column("id"),
+				sessions.Column("user_id"),
+			},
+		}).
+		Where2(users.Column("banned"), "=", true).
+		Build()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(query)
+	fmt.Println(args)
+	// Output:
+	// DELETE s FROM sessions AS s INNER JOIN users AS u ON u.id=s.user_id WHERE u.banned=?
+	// [true]
+}
+
+func ExampleMergeBuilder() {
+	var (
+		users = sqlb.NewTable("users", "u")
+		src   = sqlb.NewTable("", "s")
+	)
+	query, args, err := sqlb.NewMergeBuilder().
+		BindVar(syntax.Dollar).
+		Into(users).
+		UsingValues("s", "email", "name").
+		Values("alice@example.com", "alice").
+		On(&sqls.Segment{
+			Raw: "#c1 = #c2",
+			Columns: []*sqls.TableColumn{
+				users.Column("email"),
+				src.Column("email"),
+			},
+		}).
+		WhenMatchedUpdate(nil, &sqls.Segment{
+			Raw:     "name = #c1",
+			Columns: src.Columns("name"),
+		}).
+		WhenNotMatchedInsert(nil, []string{"email", "name"},
+			&sqls.Segment{Raw: "#c1", Columns: src.Columns("email")},
+			&sqls.Segment{Raw: "#c1", Columns: src.Columns("name")},
+		).
+		Build()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(query)
+	fmt.Println(args)
+	// Output:
+	// MERGE INTO users AS u USING (VALUES ($1, $2)) AS s (email, name) ON u.email = s.email WHEN MATCHED THEN UPDATE SET name = s.name WHEN NOT MATCHED THEN INSERT (email, name) VALUES (s.email, s.name)
+	// [alice@example.com alice]
+}
+
+func ExampleCompoundBuilder() {
+	var (
+		users  = sqlb.NewTable("users", "u")
+		admins = sqlb.NewTable("admins", "a")
+	)
+	query, args, err := sqlb.NewCompoundBuilder(
+		sqlb.NewQueryBuilder().
+			Select(users.Column("name")).
+			From(users).
+			Where2(users.Column("active"), "=", true),
+	).
+		BindVar(syntax.Dollar).
+		UnionAll(
+			sqlb.NewQueryBuilder().
+				Select(admins.Column("name")).
+				From(admins),
+		).
+		OrderBy("name", sqlb.Asc).
+		Limit(10).
+		Build()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(query)
+	fmt.Println(args)
+	// Output:
+	// (SELECT u.name FROM users AS u WHERE u.active=$1) UNION ALL (SELECT a.name FROM admins AS a) ORDER BY name ASC LIMIT 10
+	// [true]
+}
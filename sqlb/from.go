@@ -0,0 +1,375 @@
+package sqlb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qjebbs/go-sqls"
+)
+
+// fromTables is the FROM clause shared by the builders, which manages the
+// main table and the joined tables, and calculates the dependencies of them.
+type fromTables struct {
+	froms        map[Table]*fromTable // the from tables by the name and alias, see Table.key()
+	tables       []Table              // the tables in order
+	appliedNames map[sqls.Table]Table // applied table name mapping, the name is alias, or name if alias is empty
+
+	outer *fromTables // the tables of outer query, which are visible to a lateral subquery
+}
+
+type fromTable struct {
+	Segment  *sqls.Segment
+	Optional bool
+	Unique   bool // the join matches at most one row, which can be trimmed without DISTINCT
+
+	Join    string        // the join keyword, empty for the main table
+	Lateral bool          // the subquery is a lateral one
+	Source  *sqls.Segment // the table or subquery with alias, like "users AS u"
+	On      *sqls.Segment // the join condition, nil if not specified
+	Query   sqls.Builder  // the subquery, nil for a table
+	Schema  *Schema       // the schema of the table, nil if not declared
+}
+
+func newFromTables() fromTables {
+	return fromTables{
+		froms:        map[Table]*fromTable{},
+		appliedNames: make(map[sqls.Table]Table),
+	}
+}
+
+// clone returns a copy of f, which can be modified without affecting f.
+func (f *fromTables) clone() fromTables {
+	c := fromTables{
+		froms:        make(map[Table]*fromTable, len(f.froms)),
+		tables:       append([]Table(nil), f.tables...),
+		appliedNames: make(map[sqls.Table]Table, len(f.appliedNames)),
+		outer:        f.outer,
+	}
+	for t, from := range f.froms {
+		c.froms[t] = from
+	}
+	for name, t := range f.appliedNames {
+		c.appliedNames[name] = t
+	}
+	return c
+}
+
+// setFrom set the main table.
+func (f *fromTables) setFrom(t Table) error {
+	if t.Name == "" {
+		return fmt.Errorf("from table is empty")
+	}
+	f.setMain(t, tableSource(t, DialectPostgreSQL), nil)
+	return nil
+}
+
+// setFromQuery set the main table as a subquery with alias.
+func (f *fromTables) setFromQuery(builder sqls.Builder, alias sqls.Table) error {
+	source, err := querySource(builder, alias, DialectPostgreSQL)
+	if err != nil {
+		return fmt.Errorf("from query: %w", err)
+	}
+	f.setMain(NewTable("", alias), source, builder)
+	return nil
+}
+
+func (f *fromTables) setMain(t Table, source *sqls.Segment, query sqls.Builder) {
+	schema := t.schema
+	t = t.key()
+	if len(f.tables) == 0 {
+		f.tables = append(f.tables, t)
+	} else {
+		f.tables[0] = t
+	}
+	f.appliedNames[t.AppliedName()] = t
+	f.froms[t] = &fromTable{
+		Segment:  source,
+		Optional: false,
+		Source:   source,
+		Query:    query,
+		Schema:   schema,
+	}
+}
+
+// join append a join table.
+func (f *fromTables) join(joinStr string, t Table, on *sqls.Segment, optional, unique bool) error {
+	if t.Name == "" {
+		return fmt.Errorf("join table name is empty")
+	}
+	return f.addJoin(joinStr, t, tableSource(t, DialectPostgreSQL), nil, on, optional, unique)
+}
+
+// joinQuery append a join subquery with alias.
+func (f *fromTables) joinQuery(joinStr string, builder sqls.Builder, alias sqls.Table, on *sqls.Segment, optional, unique bool) error {
+	source, err := querySource(builder, alias, DialectPostgreSQL)
+	if err != nil {
+		return fmt.Errorf("join query: %w", err)
+	}
+	return f.addJoin(joinStr, NewTable("", alias), source, builder, on, optional, unique)
+}
+
+func (f *fromTables) addJoin(joinStr string, t Table, source *sqls.Segment, query sqls.Builder, on *sqls.Segment, optional, unique bool) error {
+	schema := t.schema
+	t = t.key()
+	if _, ok := f.froms[t]; ok {
+		if t.Name == "" || t.Alias == "" {
+			return fmt.Errorf("table [%s] is already joined", t.AppliedName())
+		}
+		return fmt.Errorf("table [%s AS %s] is already joined", t.Name, t.Alias)
+	}
+	if len(f.tables) == 0 {
+		// reserve the first alias for the main table
+		f.tables = append(f.tables, Table{})
+	}
+	f.tables = append(f.tables, t)
+	f.appliedNames[t.AppliedName()] = t
+	if on != nil && on.Raw == "" {
+		on = nil
+	}
+	f.froms[t] = &fromTable{
+		Segment:  joinSegment(joinStr, source, on),
+		Optional: optional,
+		Unique:   unique,
+		Join:     joinStr,
+		Source:   source,
+		On:       on,
+		Query:    query,
+		Schema:   schema,
+	}
+	return nil
+}
+
+// joinSegment returns the segment of the join, like "LEFT JOIN t AS a ON ...".
+func joinSegment(joinStr string, source *sqls.Segment, on *sqls.Segment) *sqls.Segment {
+	if on == nil {
+		return &sqls.Segment{
+			Raw:      joinStr + " #s1",
+			Segments: []*sqls.Segment{source},
+		}
+	}
+	return &sqls.Segment{
+		Raw:      joinStr + " #s1 ON #s2",
+		Segments: []*sqls.Segment{source, on},
+	}
+}
+
+// applyDialect renders the sources for the dialect, since the dialect may
+// be set after the tables are added. It replaces the from tables, so it
+// should be called on a copy, see clone().
+func (f *fromTables) applyDialect(d Dialect) {
+	if d != DialectOracle {
+		// rendered as PostgreSQL on adding
+		return
+	}
+	for t, from := range f.froms {
+		c := *from
+		if from.Query != nil {
+			// the alias is validated on adding
+			c.Source, _ = querySource(from.Query, t.Alias, d)
+		} else {
+			c.Source = tableSource(t, d)
+		}
+		c.Segment = c.Source
+		if from.Join != "" {
+			c.Segment = joinSegment(from.Join, c.Source, from.On)
+		}
+		f.froms[t] = &c
+	}
+}
+
+// querySource returns the source segment of the subquery, like "(SELECT ...) AS alias".
+func querySource(builder sqls.Builder, alias sqls.Table, d Dialect) (*sqls.Segment, error) {
+	if builder == nil {
+		return nil, fmt.Errorf("query is nil")
+	}
+	if alias == "" {
+		return nil, fmt.Errorf("alias is required")
+	}
+	return &sqls.Segment{
+		Raw:      "(#b1)" + aliasClause(alias, d),
+		Builders: []sqls.Builder{builder},
+	}, nil
+}
+
+// tableSource returns the source segment of the table, like "users AS u".
+func tableSource(t Table, d Dialect) *sqls.Segment {
+	return &sqls.Segment{Raw: tableAndAlias(t, d)}
+}
+
+// requiredJoins returns the joined tables except the trimmed optional ones.
+func (f *fromTables) requiredJoins(dep map[Table]bool) []Table {
+	joins := make([]Table, 0, len(f.tables))
+	for _, t := range f.tables[1:] {
+		if f.froms[t].Optional && !dep[t] {
+			continue
+		}
+		joins = append(joins, t)
+	}
+	return joins
+}
+
+// buildTables builds the main table and the joins, like "t AS a JOIN t2 AS b ON ...".
+func (f *fromTables) buildTables(ctx *sqls.Context, main Table, joins []Table) (string, error) {
+	tables := make([]string, 0, len(joins)+1)
+	for _, t := range append([]Table{main}, joins...) {
+		from := f.froms[t]
+		c, err := from.Segment.BuildContext(ctx)
+		if err != nil {
+			return "", fmt.Errorf("build FROM '%s': %w", from.Segment.Raw, err)
+		}
+		tables = append(tables, c)
+	}
+	return strings.Join(tables, " "), nil
+}
+
+// buildJoinsAsFrom builds the joins as the FROM / USING list of UPDATE and
+// DELETE in PostgreSQL style, e.g.: "USING t2 JOIN t3 ON ...". The target
+// table is not visible to the join conditions there, so the conditions
+// referencing it are moved to the returned conditions, which is only
+// possible for the inner joins.
+func (f *fromTables) buildJoinsAsFrom(ctx *sqls.Context, keyword string, dialect Dialect, joins []Table, conditions *sqls.Segment) (string, *sqls.Segment, error) {
+	if len(joins) == 0 {
+		return "", conditions, nil
+	}
+	target := f.tables[0].AppliedName()
+	moved := make([]*sqls.Segment, 0)
+	tables := make([]string, 0, len(joins))
+	for i, t := range joins {
+		from := f.froms[t]
+		segment := from.Segment
+		if i == 0 || referencesTable(from.On, target) {
+			if from.Join != "INNER JOIN" && from.Join != "CROSS JOIN" {
+				return "", nil, fmt.Errorf("%s to the target table is not supported by %s", from.Join, dialect)
+			}
+			if from.On != nil {
+				moved = append(moved, from.On)
+			}
+			segment = from.Source
+			if i > 0 {
+				segment = joinSegment("CROSS JOIN", from.Source, nil)
+			}
+		}
+		s, err := segment.BuildContext(ctx)
+		if err != nil {
+			return "", nil, fmt.Errorf("build %s '%s': %w", keyword, segment.Raw, err)
+		}
+		tables = append(tables, s)
+	}
+	if len(moved) > 0 {
+		conditions = &sqls.Segment{
+			Prefix:   conditions.Prefix,
+			Raw:      conditions.Raw,
+			Segments: append(moved, conditions.Segments...),
+		}
+	}
+	return keyword + " " + strings.Join(tables, " "), conditions, nil
+}
+
+// referencesTable tells if the segment references the table.
+func referencesTable(s *sqls.Segment, t sqls.Table) bool {
+	for _, ref := range extractTables(s) {
+		if ref.Table == t {
+			return true
+		}
+	}
+	return false
+}
+
+// dependencies returns the tables required by the segments, including the
+// main table and the tables that the required joins depend on.
+func (f *fromTables) dependencies(segments ...*sqls.Segment) (map[Table]bool, error) {
+	m := make(map[Table]bool)
+	// first table is the main table and always included
+	m[f.tables[0]] = true
+	for _, t := range extractTables(segments...) {
+		err := f.markDependencies(m, t.Table)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the required joins keep the tables they depend on, e.g. the outer
+	// tables of a lateral subquery.
+	for _, t := range f.tables[1:] {
+		if f.froms[t].Optional {
+			continue
+		}
+		err := f.markDependencies(m, t.AppliedName())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (f *fromTables) markDependencies(dep map[Table]bool, t sqls.Table) error {
+	ta, ok := f.appliedNames[t]
+	if !ok {
+		if f.outer.has(t) {
+			// the outer table referenced by a lateral subquery
+			return nil
+		}
+		return fmt.Errorf("table not found: '%s'", t)
+	}
+	from, ok := f.froms[ta]
+	if !ok {
+		return fmt.Errorf("from undefined: '%s'", t)
+	}
+	if dep[ta] {
+		return nil
+	}
+	dep[ta] = true
+	for _, ft := range f.referencedTables(from) {
+		if ft.Table == t {
+			continue
+		}
+		err := f.markDependencies(dep, ft.Table)
+		if err != nil {
+			return fmt.Errorf("%s: %s", ft.Source, err)
+		}
+	}
+	return nil
+}
+
+// tableAndAlias returns the table with alias, like "users AS u".
+func tableAndAlias(t Table, d Dialect) string {
+	return string(t.Name) + aliasClause(t.Alias, d)
+}
+
+// aliasClause returns the alias clause, like " AS u", or " u" for Oracle,
+// which doesn't allow the AS keyword before the table aliases.
+func aliasClause(alias sqls.Table, d Dialect) string {
+	switch {
+	case alias == "":
+		return ""
+	case d == DialectOracle:
+		return " " + string(alias)
+	default:
+		return " AS " + string(alias)
+	}
+}
+
+// has tells if the table is declared in f or its outer queries.
+func (f *fromTables) has(t sqls.Table) bool {
+	if f == nil {
+		return false
+	}
+	if _, ok := f.appliedNames[t]; ok {
+		return true
+	}
+	return f.outer.has(t)
+}
+
+// referencedTables returns the tables that the from table depends on.
+func (f *fromTables) referencedTables(from *fromTable) []*tableWithSouce {
+	tables := extractTables(from.Segment)
+	if !from.Lateral {
+		return tables
+	}
+	// the lateral subquery depends on the outer tables it references
+	for _, t := range outerReferences(from.Source.Builders[0]) {
+		if _, ok := f.appliedNames[t.Table]; ok {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
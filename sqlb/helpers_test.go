@@ -0,0 +1,24 @@
+package sqlb_test
+
+import (
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/sqlb"
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+// newQuery returns a query of the dollar bindvar and the dialect, which
+// selects the columns. It's the shared start of the test queries.
+func newQuery(d sqlb.Dialect, columns ...*sqls.TableColumn) *sqlb.QueryBuilder {
+	return sqlb.NewQueryBuilder().
+		BindVar(syntax.Dollar).
+		Dialect(d).
+		Select(columns...)
+}
+
+// eq returns the condition "a=b", e.g. a join condition.
+func eq(a, b *sqls.TableColumn) *sqls.Segment {
+	return &sqls.Segment{
+		Raw:     "#c1=#c2",
+		Columns: []*sqls.TableColumn{a, b},
+	}
+}
@@ -0,0 +1,231 @@
+package sqlb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+var _ sqls.Builder = (*InsertBuilder)(nil)
+
+// InsertBuilder is the SQL INSERT statement builder.
+type InsertBuilder struct {
+	bindVarStyle syntax.BindVarStyle // the bindvar style
+	dialect      Dialect             // the SQL dialect
+
+	table     Table         // the table to insert into
+	columns   []string      // the columns to insert
+	rows      [][]any       // the values of rows
+	selects   *QueryBuilder // the query for INSERT ... SELECT
+	returning *sqls.Segment // returning columns
+	conflict  *Conflict     // the upsert clause
+
+	errorList // errors during building
+}
+
+// NewInsertBuilder returns a new InsertBuilder.
+func NewInsertBuilder() *InsertBuilder {
+	return &InsertBuilder{
+		returning: &sqls.Segment{
+			Prefix: "RETURNING",
+			Raw:    "#join('#column', ', ')",
+		},
+	}
+}
+
+// Into set the table to insert into. The alias is supported by PostgreSQL
+// and SQLite only, use a table without alias for the other dialects.
+func (b *InsertBuilder) Into(t Table) *InsertBuilder {
+	if t.Name == "" {
+		b.pushError(fmt.Errorf("insert table is empty"))
+		return b
+	}
+	b.table = t
+	return b
+}
+
+// Columns set the columns to insert.
+func (b *InsertBuilder) Columns(columns ...string) *InsertBuilder {
+	b.columns = columns
+	return b
+}
+
+// Values appends a row of values to insert, call it many times to insert
+// multiple rows. The count of values must match the count of columns.
+//
+// A value of *sqls.Segment is rendered as an expression rather than an
+// arg, e.g.:
+//
+//	b.Values("alice", &sqls.Segment{Raw: "NOW()"})
+func (b *InsertBuilder) Values(values ...any) *InsertBuilder {
+	b.rows = append(b.rows, values)
+	return b
+}
+
+// Select set the query for INSERT ... SELECT, which conflicts with Values().
+func (b *InsertBuilder) Select(query *QueryBuilder) *InsertBuilder {
+	b.selects = query
+	return b
+}
+
+// Returning set the RETURNING columns.
+func (b *InsertBuilder) Returning(columns ...*sqls.TableColumn) *InsertBuilder {
+	b.returning.WithColumns(columns...)
+	return b
+}
+
+// BindVar set the bindvar style.
+func (b *InsertBuilder) BindVar(style syntax.BindVarStyle) *InsertBuilder {
+	b.bindVarStyle = style
+	return b
+}
+
+// Dialect set the SQL dialect, which decides how the upsert is rendered.
+func (b *InsertBuilder) Dialect(d Dialect) *InsertBuilder {
+	b.dialect = d
+	return b
+}
+
+// Build builds the query.
+func (b *InsertBuilder) Build() (query string, args []any, err error) {
+	args = make([]any, 0)
+	ctx := sqls.NewContext(&args)
+	ctx.BindVarStyle = b.bindVarStyle
+	query, err = b.buildInternal(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	return query, args, nil
+}
+
+// BuildContext builds the query with the context.
+func (b *InsertBuilder) BuildContext(ctx *sqls.Context) (query string, err error) {
+	return b.buildInternal(ctx)
+}
+
+func (b *InsertBuilder) buildInternal(ctx *sqls.Context) (string, error) {
+	if b == nil {
+		return "", nil
+	}
+	if err := b.anyError(); err != nil {
+		return "", err
+	}
+	if b.table.Name == "" {
+		return "", fmt.Errorf("no table to insert into")
+	}
+	if err := b.checkColumns(); err != nil {
+		return "", err
+	}
+	if b.conflict != nil && b.dialect == DialectSQLServer {
+		return b.buildMerge(ctx)
+	}
+	clauses := make([]string, 0)
+	if b.table.Alias != "" && b.dialect != DialectPostgreSQL && b.dialect != DialectSQLite {
+		return "", fmt.Errorf("table alias of INSERT is not supported by %s", b.dialect)
+	}
+	clauses = append(clauses, "INSERT INTO "+tableAndAlias(b.table, b.dialect))
+	if len(b.columns) > 0 {
+		clauses = append(clauses, "("+strings.Join(b.columns, ", ")+")")
+	}
+	source, err := b.buildSource(ctx)
+	if err != nil {
+		return "", err
+	}
+	clauses = append(clauses, source)
+	if b.conflict != nil {
+		upsert, err := b.conflict.build(ctx, b)
+		if err != nil {
+			return "", err
+		}
+		clauses = append(clauses, upsert)
+	}
+	returning, err := b.returning.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if returning != "" {
+		clauses = append(clauses, returning)
+	}
+	return strings.Join(clauses, " "), nil
+}
+
+// checkColumns reports the columns which are not declared in the schema of
+// the table, if any.
+func (b *InsertBuilder) checkColumns() error {
+	tables := newFromTables()
+	if err := tables.setFrom(b.table); err != nil {
+		return err
+	}
+	names := append([]string(nil), b.columns...)
+	segments := []*sqls.Segment{b.returning}
+	if c := b.conflict; c != nil {
+		names = append(names, c.target...)
+		for _, s := range c.sets {
+			if !s.all {
+				names = append(names, s.column)
+			}
+			names = append(names, s.except...)
+			segments = append(segments, s.value)
+		}
+		segments = append(segments, c.where)
+	}
+	if err := tables.checkColumnNames(names...); err != nil {
+		return err
+	}
+	return tables.checkColumns(segments...)
+}
+
+// buildSource builds the VALUES or SELECT clause of the rows to insert.
+func (b *InsertBuilder) buildSource(ctx *sqls.Context) (string, error) {
+	switch {
+	case b.selects != nil && len(b.rows) > 0:
+		return "", fmt.Errorf("both VALUES and SELECT are specified")
+	case b.selects != nil:
+		query, err := b.selects.BuildContext(ctx)
+		if err != nil {
+			return "", fmt.Errorf("build SELECT: %w", err)
+		}
+		return query, nil
+	default:
+		return b.buildValues(ctx)
+	}
+}
+
+func (b *InsertBuilder) buildValues(ctx *sqls.Context) (string, error) {
+	if len(b.rows) == 0 {
+		return "", fmt.Errorf("no values to insert")
+	}
+	if len(b.columns) == 0 {
+		return "", fmt.Errorf("no columns to insert")
+	}
+	values := &sqls.Segment{
+		Prefix: "VALUES",
+		Raw:    "#join('#segment', ', ')",
+	}
+	for i, row := range b.rows {
+		if len(row) != len(b.columns) {
+			return "", fmt.Errorf("row %d: %d values for %d columns", i+1, len(row), len(b.columns))
+		}
+		values.AppendSegments(rowSegment(row))
+	}
+	return values.BuildContext(ctx)
+}
+
+// rowSegment returns the segment of a row of values, like "($1, $2)".
+func rowSegment(values []any) *sqls.Segment {
+	seg := &sqls.Segment{}
+	refs := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(*sqls.Segment); ok {
+			seg.AppendSegments(s)
+			refs = append(refs, fmt.Sprintf("#s%d", len(seg.Segments)))
+			continue
+		}
+		seg.AppendArgs(v)
+		refs = append(refs, fmt.Sprintf("$%d", len(seg.Args)))
+	}
+	seg.Raw = "(" + strings.Join(refs, ", ") + ")"
+	return seg
+}
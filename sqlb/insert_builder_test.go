@@ -0,0 +1,175 @@
+package sqlb_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/sqlb"
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+func TestInsertBuilder(t *testing.T) {
+	users := sqlb.NewTable("users", "u")
+	testCases := []struct {
+		name      string
+		builder   *sqlb.InsertBuilder
+		wantQuery string
+		wantArgs  []any
+	}{
+		{
+			name: "multi-row values",
+			builder: sqlb.NewInsertBuilder().
+				BindVar(syntax.Dollar).
+				Into(sqlb.NewTable("users", "")).
+				Columns("name", "created_at").
+				Values("alice", &sqls.Segment{Raw: "NOW()"}).
+				Values("bob", &sqls.Segment{Raw: "NOW()"}),
+			wantQuery: "INSERT INTO users (name, created_at) VALUES ($1, NOW()), ($2, NOW())",
+			wantArgs:  []any{"alice", "bob"},
+		},
+		{
+			name: "question bindvar with returning",
+			builder: sqlb.NewInsertBuilder().
+				BindVar(syntax.Question).
+				Into(users).
+				Columns("name", "age").
+				Values("alice", 18).
+				Returning(users.Columns("id", "name")...),
+			wantQuery: "INSERT INTO users AS u (name, age) VALUES (?, ?) RETURNING u.id, u.name",
+			wantArgs:  []any{"alice", 18},
+		},
+		{
+			name: "insert select",
+			builder: sqlb.NewInsertBuilder().
+				BindVar(syntax.Dollar).
+				Into(sqlb.NewTable("archived_users", "")).
+				Columns("id", "name").
+				Select(
+					sqlb.NewQueryBuilder().
+						Select(users.Columns("id", "name")...).
+						From(users).
+						Where2(users.Column("deleted"), "=", true),
+				),
+			wantQuery: "INSERT INTO archived_users (id, name) SELECT u.id, u.name FROM users AS u WHERE u.deleted=$1",
+			wantArgs:  []any{true},
+		},
+		{
+			name: "sqlite alias",
+			builder: sqlb.NewInsertBuilder().
+				BindVar(syntax.Question).
+				Dialect(sqlb.DialectSQLite).
+				Into(users).
+				Columns("name").
+				Values("alice").
+				Returning(users.Column("id")),
+			wantQuery: "INSERT INTO users AS u (name) VALUES (?) RETURNING u.id",
+			wantArgs:  []any{"alice"},
+		},
+		{
+			name: "mysql without alias",
+			builder: sqlb.NewInsertBuilder().
+				BindVar(syntax.Question).
+				Dialect(sqlb.DialectMySQL).
+				Into(sqlb.NewTable("users", "")).
+				Columns("name").
+				Values("alice"),
+			wantQuery: "INSERT INTO users (name) VALUES (?)",
+			wantArgs:  []any{"alice"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotQuery, gotArgs, err := tc.builder.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotQuery != tc.wantQuery {
+				t.Errorf("got:\n%s\nwant:\n%s", gotQuery, tc.wantQuery)
+			}
+			if !reflect.DeepEqual(gotArgs, tc.wantArgs) {
+				t.Errorf("got:\n%#v\nwant:\n%#v", gotArgs, tc.wantArgs)
+			}
+		})
+	}
+}
+
+func TestInsertBuilderErrors(t *testing.T) {
+	users := sqlb.NewTable("users", "")
+	typed := users.WithSchema(sqlb.NewSchema(
+		&sqlb.ColumnDef{Name: "id", Type: reflect.TypeOf(int64(0)), PrimaryKey: true},
+		&sqlb.ColumnDef{Name: "name", Type: reflect.TypeOf("")},
+	))
+	query := sqlb.NewQueryBuilder().Select(users.Column("name")).From(users)
+	upsert := sqlb.NewInsertBuilder().Into(typed).Columns("name").Values("alice")
+	upsert.OnConflict("id").DoUpdate("nmae")
+	testCases := []struct {
+		name    string
+		builder *sqlb.InsertBuilder
+		wantErr string
+	}{
+		{
+			name:    "empty table",
+			builder: sqlb.NewInsertBuilder().Into(sqlb.Table{}).Columns("name").Values("alice"),
+			wantErr: "insert table is empty",
+		},
+		{
+			name:    "values count mismatch",
+			builder: sqlb.NewInsertBuilder().Into(users).Columns("name", "age").Values("alice", 18).Values("bob"),
+			wantErr: "row 2: 1 values for 2 columns",
+		},
+		{
+			name:    "no columns",
+			builder: sqlb.NewInsertBuilder().Into(users).Values("alice"),
+			wantErr: "no columns to insert",
+		},
+		{
+			name:    "no values",
+			builder: sqlb.NewInsertBuilder().Into(users).Columns("name"),
+			wantErr: "no values to insert",
+		},
+		{
+			name:    "both values and select",
+			builder: sqlb.NewInsertBuilder().Into(users).Columns("name").Values("alice").Select(query),
+			wantErr: "both VALUES and SELECT are specified",
+		},
+		{
+			name:    "undeclared column",
+			builder: sqlb.NewInsertBuilder().Into(typed).Columns("nmae").Values("alice"),
+			wantErr: "column 'nmae' is not declared in table 'users'",
+		},
+		{
+			name:    "undeclared returning column",
+			builder: sqlb.NewInsertBuilder().Into(typed).Columns("name").Values("alice").Returning(typed.Column("uid")),
+			wantErr: "column 'uid' is not declared in table 'users'",
+		},
+		{
+			name:    "undeclared conflict column",
+			builder: upsert,
+			wantErr: "column 'nmae' is not declared in table 'users'",
+		},
+	}
+	for _, d := range []sqlb.Dialect{sqlb.DialectMySQL, sqlb.DialectSQLServer, sqlb.DialectOracle} {
+		testCases = append(testCases, struct {
+			name    string
+			builder *sqlb.InsertBuilder
+			wantErr string
+		}{
+			name:    "alias of " + d.String(),
+			builder: sqlb.NewInsertBuilder().Dialect(d).Into(sqlb.NewTable("users", "u")).Columns("name").Values("alice"),
+			wantErr: "table alias of INSERT is not supported by " + d.String(),
+		})
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := tc.builder.Build()
+			if err == nil {
+				t.Fatal("want error, got nil")
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %q, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
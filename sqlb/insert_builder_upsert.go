@@ -0,0 +1,333 @@
+package sqlb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+// Conflict is the upsert clause of InsertBuilder, which is rendered
+// according to the dialect of the builder:
+//
+//   - PostgreSQL, SQLite: INSERT ... ON CONFLICT ... DO NOTHING / DO UPDATE SET ...
+//   - MySQL: INSERT ... ON DUPLICATE KEY UPDATE ...
+//   - SQL Server: MERGE INTO ... USING (...) AS excluded ...
+//
+// The inserted values are referenced as "EXCLUDED.column", which is also the
+// source alias of the SQL Server MERGE statement. For MySQL, the references
+// in DoUpdateSet() are rewritten to "VALUES(column)", or "alias.column" with
+// RowAlias(), so that the expressions can be shared among them.
+type Conflict struct {
+	target     []string       // the conflict target columns
+	constraint string         // the conflict target constraint
+	doNothing  bool           // DO NOTHING
+	sets       []*conflictSet // DO UPDATE SET items
+	where      *sqls.Segment  // conditions of DO UPDATE
+	rowAlias   string         // the row alias of MySQL
+}
+
+// conflictSet is an item of DO UPDATE SET
+type conflictSet struct {
+	column string        // the column to update
+	value  *sqls.Segment // the value, nil for the inserted value
+	except []string      // the columns to exclude, if all
+	all    bool          // all insert columns
+}
+
+// OnConflict adds the upsert clause with the conflict target columns.
+// The target is ignored by MySQL, which checks all the unique keys.
+//
+// For example:
+//
+//	b.OnConflict("email").DoUpdate("name")
+//	// INSERT ... ON CONFLICT (email) DO UPDATE SET name = EXCLUDED.name
+func (b *InsertBuilder) OnConflict(columns ...string) *Conflict {
+	b.conflict = &Conflict{
+		target: columns,
+		where: &sqls.Segment{
+			Raw: "#join('#segment', ' AND ')",
+		},
+	}
+	return b.conflict
+}
+
+// OnConflictConstraint adds the upsert clause with the conflict target
+// constraint, which is supported by PostgreSQL only.
+func (b *InsertBuilder) OnConflictConstraint(name string) *Conflict {
+	c := b.OnConflict()
+	c.constraint = name
+	return c
+}
+
+// DoNothing ignores the conflicted rows.
+func (c *Conflict) DoNothing() *Conflict {
+	c.doNothing = true
+	return c
+}
+
+// DoUpdate updates the columns with the inserted values on conflict.
+func (c *Conflict) DoUpdate(columns ...string) *Conflict {
+	for _, col := range columns {
+		c.sets = append(c.sets, &conflictSet{column: col})
+	}
+	return c
+}
+
+// DoUpdateSet updates the column with the value on conflict. e.g.:
+//
+//	c.DoUpdateSet("count", &sqls.Segment{
+//		Raw:     "#c1 + EXCLUDED.count",
+//		Columns: t.Columns("count"),
+//	})
+func (c *Conflict) DoUpdateSet(column string, value *sqls.Segment) *Conflict {
+	c.sets = append(c.sets, &conflictSet{column: column, value: value})
+	return c
+}
+
+// DoUpdateAllExcept updates all the insert columns except the given ones with
+// the inserted values on conflict. The columns updated by other DoUpdate*()
+// calls are excluded too.
+func (c *Conflict) DoUpdateAllExcept(columns ...string) *Conflict {
+	c.sets = append(c.sets, &conflictSet{except: columns, all: true})
+	return c
+}
+
+// Where adds a condition for DO UPDATE, which is not supported by MySQL.
+func (c *Conflict) Where(s *sqls.Segment) *Conflict {
+	if s == nil {
+		return c
+	}
+	c.where.AppendSegments(s)
+	return c
+}
+
+// RowAlias set the row alias of the inserted values for MySQL 8.0.19+,
+// which replaces the deprecated VALUES() function. e.g.:
+//
+//	INSERT INTO t (a) VALUES (?) AS new ON DUPLICATE KEY UPDATE a = new.a
+func (c *Conflict) RowAlias(alias string) *Conflict {
+	c.rowAlias = alias
+	return c
+}
+
+// build builds the upsert clause for the dialects except SQL Server.
+func (c *Conflict) build(ctx *sqls.Context, b *InsertBuilder) (string, error) {
+	sets, err := c.resolveSets(b.columns)
+	if err != nil {
+		return "", err
+	}
+	switch b.dialect {
+	case DialectPostgreSQL, DialectSQLite:
+		return c.buildOnConflict(ctx, b.dialect, sets)
+	case DialectMySQL:
+		return c.buildOnDuplicateKey(ctx, b, sets)
+	default:
+		return "", fmt.Errorf("upsert is not supported by %s", b.dialect)
+	}
+}
+
+func (c *Conflict) buildOnConflict(ctx *sqls.Context, dialect Dialect, sets []*conflictSet) (string, error) {
+	clauses := []string{"ON CONFLICT"}
+	switch {
+	case c.constraint != "":
+		if dialect != DialectPostgreSQL {
+			return "", fmt.Errorf("conflict constraint is not supported by %s", dialect)
+		}
+		clauses = append(clauses, "ON CONSTRAINT "+c.constraint)
+	case len(c.target) > 0:
+		clauses = append(clauses, "("+strings.Join(c.target, ", ")+")")
+	case !c.doNothing:
+		return "", fmt.Errorf("conflict target is required for DO UPDATE")
+	}
+	if c.doNothing {
+		return strings.Join(append(clauses, "DO NOTHING"), " "), nil
+	}
+	set, err := buildConflictSets(ctx, sets, "EXCLUDED.%s", false)
+	if err != nil {
+		return "", err
+	}
+	clauses = append(clauses, "DO UPDATE SET "+set)
+	where, err := c.where.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if where != "" {
+		clauses = append(clauses, "WHERE "+where)
+	}
+	return strings.Join(clauses, " "), nil
+}
+
+func (c *Conflict) buildOnDuplicateKey(ctx *sqls.Context, b *InsertBuilder, sets []*conflictSet) (string, error) {
+	if len(c.where.Segments) > 0 {
+		return "", fmt.Errorf("conflict condition is not supported by %s", b.dialect)
+	}
+	alias := ""
+	if c.rowAlias != "" {
+		if b.selects != nil {
+			return "", fmt.Errorf("row alias is not supported by INSERT ... SELECT")
+		}
+		alias = "AS " + c.rowAlias + " "
+	}
+	if c.doNothing {
+		// MySQL has no DO NOTHING, update a column to itself instead,
+		// which doesn't hide other errors like INSERT IGNORE does.
+		if len(b.columns) == 0 {
+			return "", fmt.Errorf("no columns to insert")
+		}
+		return fmt.Sprintf("%sON DUPLICATE KEY UPDATE %[2]s = %[2]s", alias, b.columns[0]), nil
+	}
+	excluded := "VALUES(%s)"
+	if c.rowAlias != "" {
+		excluded = c.rowAlias + ".%s"
+	}
+	set, err := buildConflictSets(ctx, sets, excluded, true)
+	if err != nil {
+		return "", err
+	}
+	return alias + "ON DUPLICATE KEY UPDATE " + set, nil
+}
+
+// buildMerge builds the upsert as a MERGE statement for SQL Server.
+func (b *InsertBuilder) buildMerge(ctx *sqls.Context) (string, error) {
+	c := b.conflict
+	if c.constraint != "" {
+		return "", fmt.Errorf("conflict constraint is not supported by %s", b.dialect)
+	}
+	if len(c.target) == 0 {
+		return "", fmt.Errorf("conflict target is required by %s", b.dialect)
+	}
+	if len(b.columns) == 0 {
+		return "", fmt.Errorf("no columns to insert")
+	}
+	if len(b.returning.Columns) > 0 {
+		return "", fmt.Errorf("RETURNING is not supported by %s", b.dialect)
+	}
+	sets, err := c.resolveSets(b.columns)
+	if err != nil {
+		return "", err
+	}
+	target := b.table.AppliedName()
+	// unlike INSERT, MERGE accepts the target alias
+	clauses := []string{"MERGE INTO " + tableAndAlias(b.table, b.dialect)}
+	source, err := b.buildSource(ctx)
+	if err != nil {
+		return "", err
+	}
+	clauses = append(clauses, fmt.Sprintf(
+		"USING (%s) AS excluded (%s)",
+		source, strings.Join(b.columns, ", "),
+	))
+	on := make([]string, 0, len(c.target))
+	for _, col := range c.target {
+		on = append(on, fmt.Sprintf("%s.%s = excluded.%[2]s", target, col))
+	}
+	clauses = append(clauses, "ON "+strings.Join(on, " AND "))
+	if !c.doNothing {
+		set, err := buildConflictSets(ctx, sets, "excluded.%s", false)
+		if err != nil {
+			return "", err
+		}
+		where, err := c.where.BuildContext(ctx)
+		if err != nil {
+			return "", err
+		}
+		if where != "" {
+			where = " AND " + where
+		}
+		clauses = append(clauses, fmt.Sprintf("WHEN MATCHED%s THEN UPDATE SET %s", where, set))
+	}
+	values := make([]string, 0, len(b.columns))
+	for _, col := range b.columns {
+		values = append(values, "excluded."+col)
+	}
+	clauses = append(clauses, fmt.Sprintf(
+		"WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)",
+		strings.Join(b.columns, ", "), strings.Join(values, ", "),
+	))
+	return strings.Join(clauses, " ") + ";", nil
+}
+
+// resolveSets expands the DoUpdateAllExcept() items with the insert columns.
+func (c *Conflict) resolveSets(columns []string) ([]*conflictSet, error) {
+	if c.doNothing {
+		if len(c.sets) > 0 {
+			return nil, fmt.Errorf("both DO NOTHING and DO UPDATE are specified")
+		}
+		return nil, nil
+	}
+	explicit := make(map[string]bool)
+	for _, s := range c.sets {
+		if s.all {
+			continue
+		}
+		if explicit[s.column] {
+			return nil, fmt.Errorf("column '%s' is updated more than once", s.column)
+		}
+		explicit[s.column] = true
+	}
+	sets := make([]*conflictSet, 0, len(c.sets))
+	for _, s := range c.sets {
+		if !s.all {
+			sets = append(sets, s)
+			continue
+		}
+		except := make(map[string]bool)
+		for _, col := range s.except {
+			except[col] = true
+		}
+		for _, col := range columns {
+			if except[col] || explicit[col] {
+				continue
+			}
+			explicit[col] = true
+			sets = append(sets, &conflictSet{column: col})
+		}
+	}
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("no columns to update on conflict, call DoNothing() or DoUpdate*()")
+	}
+	return sets, nil
+}
+
+// buildConflictSets builds the SET items, the excluded is the format of
+// the inserted value reference, e.g. "EXCLUDED.%s". If rewrite is true, the
+// "EXCLUDED.column" references in the values are rewritten to excluded.
+func buildConflictSets(ctx *sqls.Context, sets []*conflictSet, excluded string, rewrite bool) (string, error) {
+	items := make([]string, 0, len(sets))
+	for _, s := range sets {
+		if s.value == nil {
+			items = append(items, s.column+" = "+fmt.Sprintf(excluded, s.column))
+			continue
+		}
+		value, err := s.value.BuildContext(ctx)
+		if err != nil {
+			return "", fmt.Errorf("build value of '%s': %w", s.column, err)
+		}
+		if rewrite {
+			value = rewriteExcluded(value, excluded)
+		}
+		items = append(items, s.column+" = "+value)
+	}
+	return strings.Join(items, ", "), nil
+}
+
+// rewriteExcluded rewrites the "EXCLUDED.column" references in the built
+// query with the format, the quoted strings and comments are kept intact.
+func rewriteExcluded(query string, format string) string {
+	tokens := syntax.ScanSQL(query)
+	b := new(strings.Builder)
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.Type == syntax.SQLWord && strings.EqualFold(t.Text, "EXCLUDED") &&
+			(i == 0 || tokens[i-1].Text != ".") &&
+			i+2 < len(tokens) && tokens[i+1].Text == "." && tokens[i+2].Type == syntax.SQLWord {
+			fmt.Fprintf(b, format, tokens[i+2].Text)
+			i += 2
+			continue
+		}
+		b.WriteString(t.Text)
+	}
+	return b.String()
+}
@@ -0,0 +1,225 @@
+package sqlb_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/sqlb"
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+func TestInsertBuilderUpsert(t *testing.T) {
+	users := sqlb.NewTable("users", "u")
+	testCases := []struct {
+		name      string
+		dialect   sqlb.Dialect
+		conflict  func(b *sqlb.InsertBuilder)
+		wantQuery string
+		wantArgs  []any
+	}{
+		{
+			name: "postgres do nothing",
+			conflict: func(b *sqlb.InsertBuilder) {
+				b.OnConflict().DoNothing()
+			},
+			wantQuery: "INSERT INTO users AS u (email, name, age) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+			wantArgs:  []any{"alice@example.com", "alice", 18},
+		},
+		{
+			name: "postgres do update with where",
+			conflict: func(b *sqlb.InsertBuilder) {
+				b.OnConflict("email").
+					DoUpdate("name").
+					DoUpdateSet("age", &sqls.Segment{
+						Raw:     "GREATEST(#c1, EXCLUDED.age)",
+						Columns: users.Columns("age"),
+					}).
+					Where(&sqls.Segment{
+						Raw:     "#c1 = $1",
+						Columns: users.Columns("locked"),
+						Args:    []any{false},
+					})
+				b.Returning(users.Column("id"))
+			},
+			wantQuery: "INSERT INTO users AS u (email, name, age) VALUES ($1, $2, $3) ON CONFLICT (email) DO UPDATE SET name = EXCLUDED.name, age = GREATEST(u.age, EXCLUDED.age) WHERE u.locked = $4 RETURNING u.id",
+			wantArgs:  []any{"alice@example.com", "alice", 18, false},
+		},
+		{
+			name: "postgres constraint and all except",
+			conflict: func(b *sqlb.InsertBuilder) {
+				b.OnConflictConstraint("users_email_key").DoUpdateAllExcept("email")
+			},
+			wantQuery: "INSERT INTO users AS u (email, name, age) VALUES ($1, $2, $3) ON CONFLICT ON CONSTRAINT users_email_key DO UPDATE SET name = EXCLUDED.name, age = EXCLUDED.age",
+			wantArgs:  []any{"alice@example.com", "alice", 18},
+		},
+		{
+			name:    "sqlite do update",
+			dialect: sqlb.DialectSQLite,
+			conflict: func(b *sqlb.InsertBuilder) {
+				b.OnConflict("email").DoUpdate("name")
+			},
+			wantQuery: "INSERT INTO users AS u (email, name, age) VALUES ($1, $2, $3) ON CONFLICT (email) DO UPDATE SET name = EXCLUDED.name",
+			wantArgs:  []any{"alice@example.com", "alice", 18},
+		},
+		{
+			name:    "mysql values function",
+			dialect: sqlb.DialectMySQL,
+			conflict: func(b *sqlb.InsertBuilder) {
+				b.OnConflict("email").DoUpdateAllExcept("email")
+			},
+			wantQuery: "INSERT INTO users (email, name, age) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE name = VALUES(name), age = VALUES(age)",
+			wantArgs:  []any{"alice@example.com", "alice", 18},
+		},
+		{
+			name:    "mysql row alias",
+			dialect: sqlb.DialectMySQL,
+			conflict: func(b *sqlb.InsertBuilder) {
+				b.OnConflict().RowAlias("new").DoUpdate("name")
+			},
+			wantQuery: "INSERT INTO users (email, name, age) VALUES (?, ?, ?) AS new ON DUPLICATE KEY UPDATE name = new.name",
+			wantArgs:  []any{"alice@example.com", "alice", 18},
+		},
+		{
+			name:    "mysql do update set",
+			dialect: sqlb.DialectMySQL,
+			conflict: func(b *sqlb.InsertBuilder) {
+				b.OnConflict("email").
+					DoUpdateSet("age", &sqls.Segment{
+						Raw:     "GREATEST(#c1, EXCLUDED.age) + $1",
+						Columns: users.WithAlias("").Columns("age"),
+						Args:    []any{1},
+					}).
+					DoUpdateSet("name", &sqls.Segment{Raw: "CONCAT(excluded.name, ' EXCLUDED.name')"})
+			},
+			wantQuery: "INSERT INTO users (email, name, age) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE age = GREATEST(users.age, VALUES(age)) + ?, name = CONCAT(VALUES(name), ' EXCLUDED.name')",
+			wantArgs:  []any{"alice@example.com", "alice", 18, 1},
+		},
+		{
+			name:    "mysql do update set with row alias",
+			dialect: sqlb.DialectMySQL,
+			conflict: func(b *sqlb.InsertBuilder) {
+				b.OnConflict().RowAlias("new").
+					DoUpdateSet("age", &sqls.Segment{
+						Raw:     "#c1 + EXCLUDED.age",
+						Columns: users.WithAlias("").Columns("age"),
+					})
+			},
+			wantQuery: "INSERT INTO users (email, name, age) VALUES (?, ?, ?) AS new ON DUPLICATE KEY UPDATE age = users.age + new.age",
+			wantArgs:  []any{"alice@example.com", "alice", 18},
+		},
+		{
+			name:    "mysql do nothing",
+			dialect: sqlb.DialectMySQL,
+			conflict: func(b *sqlb.InsertBuilder) {
+				b.OnConflict().DoNothing()
+			},
+			wantQuery: "INSERT INTO users (email, name, age) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE email = email",
+			wantArgs:  []any{"alice@example.com", "alice", 18},
+		},
+		{
+			name:    "sqlserver merge",
+			dialect: sqlb.DialectSQLServer,
+			conflict: func(b *sqlb.InsertBuilder) {
+				b.OnConflict("email").
+					DoUpdate("name").
+					Where(&sqls.Segment{
+						Raw:     "#c1 < excluded.age",
+						Columns: users.Columns("age"),
+					})
+			},
+			wantQuery: "MERGE INTO users AS u USING (VALUES ($1, $2, $3)) AS excluded (email, name, age) ON u.email = excluded.email WHEN MATCHED AND u.age < excluded.age THEN UPDATE SET name = excluded.name WHEN NOT MATCHED THEN INSERT (email, name, age) VALUES (excluded.email, excluded.name, excluded.age);",
+			wantArgs:  []any{"alice@example.com", "alice", 18},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			style, table := syntax.Dollar, users
+			if tc.dialect == sqlb.DialectMySQL {
+				// MySQL doesn't allow the alias of INSERT
+				style, table = syntax.Question, users.WithAlias("")
+			}
+			b := sqlb.NewInsertBuilder().
+				BindVar(style).
+				Dialect(tc.dialect).
+				Into(table).
+				Columns("email", "name", "age").
+				Values("alice@example.com", "alice", 18)
+			tc.conflict(b)
+			gotQuery, gotArgs, err := b.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotQuery != tc.wantQuery {
+				t.Errorf("got:\n%s\nwant:\n%s", gotQuery, tc.wantQuery)
+			}
+			if !reflect.DeepEqual(gotArgs, tc.wantArgs) {
+				t.Errorf("got:\n%#v\nwant:\n%#v", gotArgs, tc.wantArgs)
+			}
+		})
+	}
+}
+
+func TestInsertBuilderUpsertErrors(t *testing.T) {
+	users := sqlb.NewTable("users", "")
+	testCases := []struct {
+		name     string
+		dialect  sqlb.Dialect
+		conflict func(b *sqlb.InsertBuilder)
+		wantErr  string
+	}{
+		{
+			name:     "no action",
+			conflict: func(b *sqlb.InsertBuilder) { b.OnConflict("email") },
+			wantErr:  "no columns to update on conflict",
+		},
+		{
+			name:     "no target for do update",
+			conflict: func(b *sqlb.InsertBuilder) { b.OnConflict().DoUpdate("name") },
+			wantErr:  "conflict target is required for DO UPDATE",
+		},
+		{
+			name:     "duplicated set",
+			conflict: func(b *sqlb.InsertBuilder) { b.OnConflict("email").DoUpdate("name", "name") },
+			wantErr:  "column 'name' is updated more than once",
+		},
+		{
+			name:     "sqlite constraint",
+			dialect:  sqlb.DialectSQLite,
+			conflict: func(b *sqlb.InsertBuilder) { b.OnConflictConstraint("users_email_key").DoNothing() },
+			wantErr:  "conflict constraint is not supported by SQLite",
+		},
+		{
+			name:    "mysql where",
+			dialect: sqlb.DialectMySQL,
+			conflict: func(b *sqlb.InsertBuilder) {
+				b.OnConflict().DoUpdate("name").Where(&sqls.Segment{Raw: "1=1"})
+			},
+			wantErr: "conflict condition is not supported by MySQL",
+		},
+		{
+			name:     "sqlserver without target",
+			dialect:  sqlb.DialectSQLServer,
+			conflict: func(b *sqlb.InsertBuilder) { b.OnConflict().DoNothing() },
+			wantErr:  "conflict target is required by SQL Server",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := sqlb.NewInsertBuilder().
+				Dialect(tc.dialect).
+				Into(users).
+				Columns("email", "name").
+				Values("alice@example.com", "alice")
+			tc.conflict(b)
+			_, _, err := b.Build()
+			if err == nil {
+				t.Fatal("want error, got nil")
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %q, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,424 @@
+package sqlb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+var _ sqls.Builder = (*MergeBuilder)(nil)
+
+// MergeBuilder is the SQL MERGE statement builder, which is supported by
+// PostgreSQL 15+, SQL Server and Oracle.
+//
+// For example:
+//
+//	MERGE INTO users AS u
+//	USING (VALUES ($1, $2)) AS s (email, name)
+//	ON u.email = s.email
+//	WHEN MATCHED AND u.locked = $3 THEN DELETE
+//	WHEN MATCHED THEN UPDATE SET name = s.name
+//	WHEN NOT MATCHED THEN INSERT (email, name) VALUES (s.email, s.name)
+//
+// Oracle supports only one UPDATE branch with an optional DELETE, and one
+// INSERT branch, the conditions of which are rendered as WHERE clauses.
+type MergeBuilder struct {
+	bindVarStyle syntax.BindVarStyle // the bindvar style
+	dialect      Dialect             // the SQL dialect
+
+	target   Table          // the target table
+	source   mergeSource    // the source
+	on       *sqls.Segment  // the join condition
+	branches []*mergeBranch // WHEN [NOT] MATCHED branches
+
+	errorList // errors during building
+}
+
+// mergeSource is the source of MERGE, one of table, query and values.
+type mergeSource struct {
+	table   Table        // the source table, the alias is used for query and values
+	query   sqls.Builder // the source query
+	columns []string     // the columns of values
+	rows    [][]any      // the values of rows
+}
+
+// mergeBranch is a WHEN [NOT] MATCHED branch.
+type mergeBranch struct {
+	matched bool            // WHEN MATCHED or WHEN NOT MATCHED
+	action  string          // UPDATE, DELETE or INSERT
+	cond    *sqls.Segment   // the additional condition
+	sets    []*sqls.Segment // SET items of UPDATE
+	columns []string        // columns of INSERT
+	values  []*sqls.Segment // values of INSERT
+}
+
+// NewMergeBuilder returns a new MergeBuilder.
+func NewMergeBuilder() *MergeBuilder {
+	return &MergeBuilder{}
+}
+
+// Into set the target table.
+func (b *MergeBuilder) Into(t Table) *MergeBuilder {
+	if t.Name == "" {
+		b.pushError(fmt.Errorf("merge target table is empty"))
+		return b
+	}
+	b.target = t
+	return b
+}
+
+// Using set the source table.
+func (b *MergeBuilder) Using(t Table) *MergeBuilder {
+	if t.Name == "" {
+		b.pushError(fmt.Errorf("merge source table is empty"))
+		return b
+	}
+	b.source = mergeSource{table: t}
+	return b
+}
+
+// UsingQuery set the source query with the alias.
+func (b *MergeBuilder) UsingQuery(query sqls.Builder, alias sqls.Table) *MergeBuilder {
+	if query == nil || alias == "" {
+		b.pushError(fmt.Errorf("merge source query and alias are required"))
+		return b
+	}
+	b.source = mergeSource{table: NewTable("", alias), query: query}
+	return b
+}
+
+// UsingValues set the source as a VALUES list with the alias and columns,
+// the rows are added by Values().
+func (b *MergeBuilder) UsingValues(alias sqls.Table, columns ...string) *MergeBuilder {
+	if alias == "" || len(columns) == 0 {
+		b.pushError(fmt.Errorf("merge source alias and columns are required"))
+		return b
+	}
+	b.source = mergeSource{table: NewTable("", alias), columns: columns}
+	return b
+}
+
+// Values appends a row of values to the source declared by UsingValues().
+// A value of *sqls.Segment is rendered as an expression rather than an arg.
+func (b *MergeBuilder) Values(values ...any) *MergeBuilder {
+	b.source.rows = append(b.source.rows, values)
+	return b
+}
+
+// On set the join condition of the target and source.
+func (b *MergeBuilder) On(s *sqls.Segment) *MergeBuilder {
+	b.on = s
+	return b
+}
+
+// WhenMatchedUpdate adds a "WHEN MATCHED [AND cond] THEN UPDATE SET ..." branch,
+// the cond is optional. e.g.:
+//
+//	b.WhenMatchedUpdate(nil, &sqls.Segment{
+//		Raw:     "name = #c1",
+//		Columns: src.Columns("name"),
+//	})
+func (b *MergeBuilder) WhenMatchedUpdate(cond *sqls.Segment, sets ...*sqls.Segment) *MergeBuilder {
+	if len(sets) == 0 {
+		b.pushError(fmt.Errorf("WHEN MATCHED THEN UPDATE: no columns to update"))
+		return b
+	}
+	b.branches = append(b.branches, &mergeBranch{
+		matched: true,
+		action:  "UPDATE",
+		cond:    cond,
+		sets:    sets,
+	})
+	return b
+}
+
+// WhenMatchedDelete adds a "WHEN MATCHED [AND cond] THEN DELETE" branch,
+// the cond is optional.
+func (b *MergeBuilder) WhenMatchedDelete(cond *sqls.Segment) *MergeBuilder {
+	b.branches = append(b.branches, &mergeBranch{
+		matched: true,
+		action:  "DELETE",
+		cond:    cond,
+	})
+	return b
+}
+
+// WhenNotMatchedInsert adds a "WHEN NOT MATCHED [AND cond] THEN INSERT ..."
+// branch, the cond is optional. e.g.:
+//
+//	b.WhenNotMatchedInsert(nil, []string{"email", "name"},
+//		&sqls.Segment{Raw: "#c1", Columns: src.Columns("email")},
+//		&sqls.Segment{Raw: "#c1", Columns: src.Columns("name")},
+//	)
+func (b *MergeBuilder) WhenNotMatchedInsert(cond *sqls.Segment, columns []string, values ...*sqls.Segment) *MergeBuilder {
+	if len(columns) != len(values) {
+		b.pushError(fmt.Errorf("WHEN NOT MATCHED THEN INSERT: %d values for %d columns", len(values), len(columns)))
+		return b
+	}
+	b.branches = append(b.branches, &mergeBranch{
+		matched: false,
+		action:  "INSERT",
+		cond:    cond,
+		columns: columns,
+		values:  values,
+	})
+	return b
+}
+
+// BindVar set the bindvar style.
+func (b *MergeBuilder) BindVar(style syntax.BindVarStyle) *MergeBuilder {
+	b.bindVarStyle = style
+	return b
+}
+
+// Dialect set the SQL dialect.
+func (b *MergeBuilder) Dialect(d Dialect) *MergeBuilder {
+	b.dialect = d
+	return b
+}
+
+// Build builds the query.
+func (b *MergeBuilder) Build() (query string, args []any, err error) {
+	args = make([]any, 0)
+	ctx := sqls.NewContext(&args)
+	ctx.BindVarStyle = b.bindVarStyle
+	query, err = b.buildInternal(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	return query, args, nil
+}
+
+// BuildContext builds the query with the context.
+func (b *MergeBuilder) BuildContext(ctx *sqls.Context) (query string, err error) {
+	return b.buildInternal(ctx)
+}
+
+func (b *MergeBuilder) buildInternal(ctx *sqls.Context) (string, error) {
+	if b == nil {
+		return "", nil
+	}
+	if err := b.anyError(); err != nil {
+		return "", err
+	}
+	switch b.dialect {
+	case DialectPostgreSQL, DialectSQLServer, DialectOracle:
+	default:
+		return "", fmt.Errorf("MERGE is not supported by %s", b.dialect)
+	}
+	if b.target.Name == "" {
+		return "", fmt.Errorf("no target table to merge into")
+	}
+	if b.on == nil || b.on.Raw == "" {
+		return "", fmt.Errorf("no ON condition of MERGE")
+	}
+	if len(b.branches) == 0 {
+		return "", fmt.Errorf("no WHEN branches of MERGE")
+	}
+	clauses := []string{"MERGE INTO " + b.aliased(string(b.target.Name), b.target.Alias)}
+	source, err := b.buildSource(ctx)
+	if err != nil {
+		return "", err
+	}
+	clauses = append(clauses, "USING "+source)
+	on, err := b.on.BuildContext(ctx)
+	if err != nil {
+		return "", fmt.Errorf("build ON: %w", err)
+	}
+	if b.dialect == DialectOracle {
+		on = "(" + on + ")"
+	}
+	clauses = append(clauses, "ON "+on)
+	var branches []string
+	if b.dialect == DialectOracle {
+		branches, err = b.buildOracleBranches(ctx)
+	} else {
+		branches, err = b.buildBranches(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+	clauses = append(clauses, branches...)
+	query := strings.Join(clauses, " ")
+	if b.dialect == DialectSQLServer {
+		// MERGE must be terminated by a semicolon in SQL Server
+		query += ";"
+	}
+	return query, nil
+}
+
+// aliased returns the table with alias, the AS keyword is not allowed by Oracle.
+func (b *MergeBuilder) aliased(table string, alias sqls.Table) string {
+	if alias == "" {
+		return table
+	}
+	if b.dialect == DialectOracle {
+		return table + " " + string(alias)
+	}
+	return table + " AS " + string(alias)
+}
+
+func (b *MergeBuilder) buildSource(ctx *sqls.Context) (string, error) {
+	src := b.source
+	switch {
+	case src.query != nil:
+		query, err := src.query.BuildContext(ctx)
+		if err != nil {
+			return "", fmt.Errorf("build source: %w", err)
+		}
+		return b.aliased("("+query+")", src.table.Alias), nil
+	case len(src.columns) > 0:
+		if b.dialect == DialectOracle {
+			return "", fmt.Errorf("VALUES source is not supported by %s", b.dialect)
+		}
+		if len(src.rows) == 0 {
+			return "", fmt.Errorf("no values of source")
+		}
+		values := &sqls.Segment{
+			Prefix: "VALUES",
+			Raw:    "#join('#segment', ', ')",
+		}
+		for i, row := range src.rows {
+			if len(row) != len(src.columns) {
+				return "", fmt.Errorf("source row %d: %d values for %d columns", i+1, len(row), len(src.columns))
+			}
+			values.AppendSegments(rowSegment(row))
+		}
+		query, err := values.BuildContext(ctx)
+		if err != nil {
+			return "", err
+		}
+		return b.aliased("("+query+")", src.table.Alias) + " (" + strings.Join(src.columns, ", ") + ")", nil
+	case src.table.Name != "":
+		return b.aliased(string(src.table.Name), src.table.Alias), nil
+	default:
+		return "", fmt.Errorf("no source of MERGE")
+	}
+}
+
+func (b *MergeBuilder) buildBranches(ctx *sqls.Context) ([]string, error) {
+	clauses := make([]string, 0, len(b.branches))
+	for _, br := range b.branches {
+		when := "WHEN MATCHED"
+		if !br.matched {
+			when = "WHEN NOT MATCHED"
+		}
+		if br.cond != nil {
+			cond, err := br.cond.BuildContext(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("build %s condition: %w", when, err)
+			}
+			if cond != "" {
+				when += " AND " + cond
+			}
+		}
+		action, err := br.buildAction(ctx)
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, when+" THEN "+action)
+	}
+	return clauses, nil
+}
+
+// buildOracleBranches builds the branches in Oracle style, e.g.:
+//
+//	WHEN MATCHED THEN UPDATE SET ... WHERE ... DELETE WHERE ...
+//	WHEN NOT MATCHED THEN INSERT (...) VALUES (...) WHERE ...
+func (b *MergeBuilder) buildOracleBranches(ctx *sqls.Context) ([]string, error) {
+	var update, del, insert *mergeBranch
+	for _, br := range b.branches {
+		var p **mergeBranch
+		switch br.action {
+		case "UPDATE":
+			p = &update
+		case "DELETE":
+			p = &del
+		default:
+			p = &insert
+		}
+		if *p != nil {
+			return nil, fmt.Errorf("multiple %s branches are not supported by %s", br.action, b.dialect)
+		}
+		*p = br
+	}
+	if del != nil && (update == nil || del.cond == nil) {
+		return nil, fmt.Errorf("DELETE branch must have a condition and follow an UPDATE branch in %s", b.dialect)
+	}
+	clauses := make([]string, 0, 2)
+	if update != nil {
+		action, err := update.buildAction(ctx)
+		if err != nil {
+			return nil, err
+		}
+		clause := "WHEN MATCHED THEN " + action
+		where, err := buildBranchWhere(ctx, update.cond)
+		if err != nil {
+			return nil, err
+		}
+		clause += where
+		if del != nil {
+			where, err := buildBranchWhere(ctx, del.cond)
+			if err != nil {
+				return nil, err
+			}
+			clause += " DELETE" + where
+		}
+		clauses = append(clauses, clause)
+	}
+	if insert != nil {
+		action, err := insert.buildAction(ctx)
+		if err != nil {
+			return nil, err
+		}
+		where, err := buildBranchWhere(ctx, insert.cond)
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, "WHEN NOT MATCHED THEN "+action+where)
+	}
+	return clauses, nil
+}
+
+// buildBranchWhere builds the condition as " WHERE cond", or empty if nil.
+func buildBranchWhere(ctx *sqls.Context, cond *sqls.Segment) (string, error) {
+	if cond == nil {
+		return "", nil
+	}
+	where, err := cond.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if where == "" {
+		return "", nil
+	}
+	return " WHERE " + where, nil
+}
+
+// buildAction builds the action of the branch, like "UPDATE SET ...".
+func (br *mergeBranch) buildAction(ctx *sqls.Context) (string, error) {
+	switch br.action {
+	case "UPDATE":
+		set, err := (&sqls.Segment{
+			Raw:      "#join('#segment', ', ')",
+			Segments: br.sets,
+		}).BuildContext(ctx)
+		if err != nil {
+			return "", fmt.Errorf("build UPDATE: %w", err)
+		}
+		return "UPDATE SET " + set, nil
+	case "DELETE":
+		return "DELETE", nil
+	default:
+		values, err := (&sqls.Segment{
+			Raw:      "#join('#segment', ', ')",
+			Segments: br.values,
+		}).BuildContext(ctx)
+		if err != nil {
+			return "", fmt.Errorf("build INSERT: %w", err)
+		}
+		return fmt.Sprintf("INSERT (%s) VALUES (%s)", strings.Join(br.columns, ", "), values), nil
+	}
+}
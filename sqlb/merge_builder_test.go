@@ -0,0 +1,182 @@
+package sqlb_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/sqlb"
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+func TestMergeBuilder(t *testing.T) {
+	var (
+		users   = sqlb.NewTable("users", "u")
+		staging = sqlb.NewTable("staging_users", "s")
+		src     = sqlb.NewTable("", "s")
+	)
+	on := &sqls.Segment{
+		Raw:     "#c1 = #c2",
+		Columns: []*sqls.TableColumn{users.Column("email"), src.Column("email")},
+	}
+	setName := &sqls.Segment{
+		Raw:     "name = #c1",
+		Columns: src.Columns("name"),
+	}
+	insertValues := []*sqls.Segment{
+		{Raw: "#c1", Columns: src.Columns("email")},
+		{Raw: "#c1", Columns: src.Columns("name")},
+	}
+	testCases := []struct {
+		name      string
+		builder   *sqlb.MergeBuilder
+		wantQuery string
+		wantArgs  []any
+	}{
+		{
+			name: "values source",
+			builder: sqlb.NewMergeBuilder().
+				BindVar(syntax.Dollar).
+				Into(users).
+				UsingValues("s", "email", "name").
+				Values("alice@example.com", "alice").
+				Values("bob@example.com", "bob").
+				On(on).
+				WhenMatchedDelete(&sqls.Segment{
+					Raw:     "#c1 = $1",
+					Columns: users.Columns("locked"),
+					Args:    []any{true},
+				}).
+				WhenMatchedUpdate(nil, setName).
+				WhenNotMatchedInsert(nil, []string{"email", "name"}, insertValues...),
+			wantQuery: "MERGE INTO users AS u USING (VALUES ($1, $2), ($3, $4)) AS s (email, name) ON u.email = s.email WHEN MATCHED AND u.locked = $5 THEN DELETE WHEN MATCHED THEN UPDATE SET name = s.name WHEN NOT MATCHED THEN INSERT (email, name) VALUES (s.email, s.name)",
+			wantArgs:  []any{"alice@example.com", "alice", "bob@example.com", "bob", true},
+		},
+		{
+			name: "query source",
+			builder: sqlb.NewMergeBuilder().
+				BindVar(syntax.Dollar).
+				Dialect(sqlb.DialectSQLServer).
+				Into(users).
+				UsingQuery(
+					sqlb.NewQueryBuilder().
+						Select(staging.Columns("email", "name")...).
+						From(staging).
+						Where2(staging.Column("batch"), "=", 7),
+					"s",
+				).
+				On(on).
+				WhenNotMatchedInsert(&sqls.Segment{
+					Raw:     "#c1 <> $1",
+					Columns: src.Columns("name"),
+					Args:    []any{""},
+				}, []string{"email", "name"}, insertValues...),
+			wantQuery: "MERGE INTO users AS u USING (SELECT s.email, s.name FROM staging_users AS s WHERE s.batch=$1) AS s ON u.email = s.email WHEN NOT MATCHED AND s.name <> $2 THEN INSERT (email, name) VALUES (s.email, s.name);",
+			wantArgs:  []any{7, ""},
+		},
+		{
+			name: "oracle",
+			builder: sqlb.NewMergeBuilder().
+				BindVar(syntax.Dollar).
+				Dialect(sqlb.DialectOracle).
+				Into(users).
+				Using(staging).
+				On(on).
+				WhenMatchedUpdate(&sqls.Segment{
+					Raw:     "#c1 = $1",
+					Columns: users.Columns("locked"),
+					Args:    []any{false},
+				}, setName).
+				WhenMatchedDelete(&sqls.Segment{
+					Raw:     "#c1 = $1",
+					Columns: src.Columns("deleted"),
+					Args:    []any{true},
+				}).
+				WhenNotMatchedInsert(nil, []string{"email", "name"}, insertValues...),
+			wantQuery: "MERGE INTO users u USING staging_users s ON (u.email = s.email) WHEN MATCHED THEN UPDATE SET name = s.name WHERE u.locked = $1 DELETE WHERE s.deleted = $2 WHEN NOT MATCHED THEN INSERT (email, name) VALUES (s.email, s.name)",
+			wantArgs:  []any{false, true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotQuery, gotArgs, err := tc.builder.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotQuery != tc.wantQuery {
+				t.Errorf("got:\n%s\nwant:\n%s", gotQuery, tc.wantQuery)
+			}
+			if !reflect.DeepEqual(gotArgs, tc.wantArgs) {
+				t.Errorf("got:\n%#v\nwant:\n%#v", gotArgs, tc.wantArgs)
+			}
+		})
+	}
+}
+
+func TestMergeBuilderErrors(t *testing.T) {
+	var (
+		users = sqlb.NewTable("users", "u")
+		src   = sqlb.NewTable("", "s")
+	)
+	on := &sqls.Segment{
+		Raw:     "#c1 = #c2",
+		Columns: []*sqls.TableColumn{users.Column("email"), src.Column("email")},
+	}
+	testCases := []struct {
+		name    string
+		builder *sqlb.MergeBuilder
+		wantErr string
+	}{
+		{
+			name:    "mysql",
+			builder: sqlb.NewMergeBuilder().Dialect(sqlb.DialectMySQL),
+			wantErr: "MERGE is not supported by MySQL",
+		},
+		{
+			name:    "no branches",
+			builder: sqlb.NewMergeBuilder().Into(users).Using(users.WithAlias("s")).On(on),
+			wantErr: "no WHEN branches of MERGE",
+		},
+		{
+			name: "values count mismatch",
+			builder: sqlb.NewMergeBuilder().
+				Into(users).
+				UsingValues("s", "email", "name").
+				Values("alice@example.com").
+				On(on).
+				WhenMatchedDelete(nil),
+			wantErr: "source row 1: 1 values for 2 columns",
+		},
+		{
+			name: "oracle multiple updates",
+			builder: sqlb.NewMergeBuilder().
+				Dialect(sqlb.DialectOracle).
+				Into(users).
+				Using(users.WithAlias("s")).
+				On(on).
+				WhenMatchedUpdate(nil, &sqls.Segment{Raw: "a = 1"}).
+				WhenMatchedUpdate(nil, &sqls.Segment{Raw: "a = 2"}),
+			wantErr: "multiple UPDATE branches are not supported by Oracle",
+		},
+		{
+			name: "collected errors",
+			builder: sqlb.NewMergeBuilder().
+				Into(sqlb.Table{}).
+				WhenMatchedUpdate(nil).
+				WhenNotMatchedInsert(nil, []string{"email"}),
+			wantErr: "collected errors: \n - merge target table is empty\n - WHEN MATCHED THEN UPDATE: no columns to update\n - WHEN NOT MATCHED THEN INSERT: 0 values for 1 columns\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := tc.builder.Build()
+			if err == nil {
+				t.Fatal("want error, got nil")
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %q, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
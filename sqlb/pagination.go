@@ -0,0 +1,85 @@
+package sqlb
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/qjebbs/go-sqls"
+)
+
+// maxMySQLLimit is the "no limit" of MySQL, which requires LIMIT for OFFSET.
+const maxMySQLLimit = "18446744073709551615"
+
+// paginationSegment returns the trailing pagination clause according to
+// the dialect:
+//
+//   - PostgreSQL: LIMIT m OFFSET n
+//   - SQLite: LIMIT m OFFSET n, or LIMIT -1 OFFSET n
+//   - MySQL: LIMIT n, m
+//   - SQL Server, Oracle: OFFSET n ROWS FETCH NEXT m ROWS ONLY
+//
+// The limit and offset are rendered as bindvars if bind is true. It returns
+// nil if there is no limit or offset.
+func paginationSegment(dialect Dialect, limit, offset int64, ordered, bind bool) (*sqls.Segment, error) {
+	if limit <= 0 && offset <= 0 {
+		return nil, nil
+	}
+	s := &sqls.Segment{}
+	value := func(v int64) string {
+		if !bind {
+			return strconv.FormatInt(v, 10)
+		}
+		s.Args = append(s.Args, v)
+		return "$" + strconv.Itoa(len(s.Args))
+	}
+	switch dialect {
+	case DialectPostgreSQL, DialectSQLite:
+		switch {
+		case limit > 0 && offset > 0:
+			s.Raw = "LIMIT " + value(limit) + " OFFSET " + value(offset)
+		case limit > 0:
+			s.Raw = "LIMIT " + value(limit)
+		case dialect == DialectSQLite:
+			s.Raw = "LIMIT -1 OFFSET " + value(offset)
+		default:
+			s.Raw = "OFFSET " + value(offset)
+		}
+	case DialectMySQL:
+		switch {
+		case limit > 0 && offset > 0:
+			s.Raw = "LIMIT " + value(offset) + ", " + value(limit)
+		case limit > 0:
+			s.Raw = "LIMIT " + value(limit)
+		default:
+			s.Raw = "LIMIT " + value(offset) + ", " + maxMySQLLimit
+		}
+	case DialectSQLServer, DialectOracle:
+		if dialect == DialectSQLServer && !ordered {
+			return nil, fmt.Errorf("OFFSET FETCH requires ORDER BY in %s", dialect)
+		}
+		switch {
+		case offset > 0 || dialect == DialectSQLServer:
+			s.Raw = "OFFSET " + value(offset) + " ROWS"
+			if limit > 0 {
+				s.Raw += " FETCH NEXT " + value(limit) + " ROWS ONLY"
+			}
+		default:
+			s.Raw = "FETCH FIRST " + value(limit) + " ROWS ONLY"
+		}
+	default:
+		return nil, fmt.Errorf("pagination is not supported by %s", dialect)
+	}
+	return s, nil
+}
+
+// topSegment returns the "TOP (m)" of SQL Server, which is used instead of
+// OFFSET FETCH for the query without offset, since it doesn't require ORDER BY.
+func topSegment(limit int64, bind bool) *sqls.Segment {
+	if !bind {
+		return &sqls.Segment{Raw: fmt.Sprintf("TOP (%d)", limit)}
+	}
+	return &sqls.Segment{
+		Raw:  "TOP ($1)",
+		Args: []any{limit},
+	}
+}
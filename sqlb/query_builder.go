@@ -14,37 +14,40 @@ var _ sqls.Builder = (*QueryBuilder)(nil)
 // more friendly API and improve segment reusability.
 type QueryBuilder struct {
 	bindVarStyle syntax.BindVarStyle // the bindvar style
-
-	ctes         []*cte               // common table expressions
-	froms        map[Table]*fromTable // the from tables by alias
-	tables       []Table              // the tables in order
-	appliedNames map[sqls.Table]Table // applied table name mapping, the name is alias, or name if alias is empty
-
-	selects    *sqls.Segment  // select columns and keep values in scanning.
-	touches    *sqls.Segment  // select columns but drop values in scanning.
-	conditions *sqls.Segment  // where conditions, joined with AND.
-	orders     *sqls.Segment  // order by columns, joined with comma.
-	groupbys   *sqls.Segment  // group by columns, joined with comma.
-	distinct   bool           // select distinct
-	limit      int64          // limit count
-	offset     int64          // offset count
-	unions     []sqls.Builder // union queries
-
-	errors []error // errors during building
-
-	debug bool // debug mode
-}
-
-type fromTable struct {
-	Segment  *sqls.Segment
-	Optional bool
+	dialect      Dialect             // the SQL dialect
+
+	ctes       []*cte // common table expressions
+	fromTables        // the from and join tables
+
+	selects        *sqls.Segment  // select columns and keep values in scanning.
+	touches        *sqls.Segment  // select columns but drop values in scanning.
+	conditions     *sqls.Segment  // where conditions, joined with AND.
+	orders         *sqls.Segment  // order by columns, joined with comma.
+	sorts          []*sortColumn  // order by columns with their orders.
+	groupbys       *sqls.Segment  // group by columns, joined with comma.
+	havings        *sqls.Segment  // having conditions, joined with AND.
+	windows        *sqls.Segment  // named windows, joined with comma.
+	distinct       bool           // select distinct
+	distinctOn     *sqls.Segment  // select distinct on columns
+	countDistinct  bool           // derived COUNT(DISTINCT pk) query
+	limit          int64          // limit count
+	offset         int64          // offset count
+	bindPagination bool           // render limit and offset as bindvars
+	unions         []sqls.Builder // union queries
+	locks          []*lockClause  // row locking clauses
+	keyset         *keyset        // keyset pagination cursor
+	relations      *Relations     // relationship graph for automatic joins
+
+	errorList // errors during building
+
+	debug       bool // debug mode
+	debugPretty bool // format the query in debug mode
 }
 
 // NewQueryBuilder returns a new QueryBuilder.
 func NewQueryBuilder() *QueryBuilder {
 	return &QueryBuilder{
-		froms:        map[Table]*fromTable{},
-		appliedNames: make(map[sqls.Table]Table),
+		fromTables: newFromTables(),
 		selects: &sqls.Segment{
 			Prefix: "SELECT",
 			Raw:    "#join('#column', ', ')",
@@ -65,6 +68,18 @@ func NewQueryBuilder() *QueryBuilder {
 			Prefix: "GROUP BY",
 			Raw:    "#join('#segment', ', ')",
 		},
+		havings: &sqls.Segment{
+			Prefix: "HAVING",
+			Raw:    "#join('#segment', ' AND ')",
+		},
+		distinctOn: &sqls.Segment{
+			Prefix: "DISTINCT ON",
+			Raw:    "(#join('#column', ', '))",
+		},
+		windows: &sqls.Segment{
+			Prefix: "WINDOW",
+			Raw:    "#join('#segment', ', ')",
+		},
 	}
 }
 
@@ -74,6 +89,30 @@ func (b *QueryBuilder) Distinct() *QueryBuilder {
 	return b
 }
 
+// DistinctOn set the columns for SELECT DISTINCT ON, which is supported
+// by PostgreSQL only. e.g., the latest order of each user:
+//
+//	b.DistinctOn(orders.Column("user_id")).
+//		OrderBy(orders.Column("user_id"), sqlb.Asc).
+//		OrderBy(orders.Column("created_at"), sqlb.Desc)
+//	// SELECT DISTINCT ON (o.user_id) ... ORDER BY o.user_id ASC, o.created_at DESC
+//
+// The leading ORDER BY columns must match the DISTINCT ON columns. The
+// optional joins are trimmed the same as Distinct().
+func (b *QueryBuilder) DistinctOn(columns ...*sqls.TableColumn) *QueryBuilder {
+	if len(columns) == 0 {
+		return b
+	}
+	b.distinctOn.WithColumns(columns...)
+	return b
+}
+
+// isDistinct reports whether the query is SELECT DISTINCT, SELECT DISTINCT ON
+// or COUNT(DISTINCT pk), where the unreferenced optional joins can be trimmed.
+func (b *QueryBuilder) isDistinct() bool {
+	return b.distinct || b.countDistinct || len(b.distinctOn.Columns) > 0
+}
+
 // Select replace the SELECT clause with the columns.
 func (b *QueryBuilder) Select(columns ...*sqls.TableColumn) *QueryBuilder {
 	if len(columns) == 0 {
@@ -105,6 +144,30 @@ var orders = []string{
 	"DESC NULLS LAST",
 }
 
+// sortColumn is a column of ORDER BY with its order.
+type sortColumn struct {
+	column *sqls.TableColumn
+	order  Order
+}
+
+// reverse returns the reversed order, e.g.: ASC NULLS LAST -> DESC NULLS FIRST.
+func (o Order) reverse() Order {
+	switch o {
+	case Asc:
+		return Desc
+	case AscNullsFirst:
+		return DescNullsLast
+	case AscNullsLast:
+		return DescNullsFirst
+	case Desc:
+		return Asc
+	case DescNullsFirst:
+		return AscNullsLast
+	default:
+		return AscNullsFirst
+	}
+}
+
 // OrderBy set the sorting order. the order can be "ASC", "DESC", "ASC NULLS FIRST" or "DESC NULLS LAST"
 func (b *QueryBuilder) OrderBy(column *sqls.TableColumn, order Order) *QueryBuilder {
 	idx := len(b.orders.Segments) + 1
@@ -112,8 +175,10 @@ func (b *QueryBuilder) OrderBy(column *sqls.TableColumn, order Order) *QueryBuil
 
 	if order > DescNullsLast {
 		b.pushError(fmt.Errorf("invalid order: %d", order))
+		return b
 	}
 	orderStr := orders[order]
+	b.sorts = append(b.sorts, &sortColumn{column: column, order: order})
 	// pq: for SELECT DISTINCT, ORDER BY expressions must appear in select list
 	b.touches.AppendSegments(&sqls.Segment{
 		Raw:     "#c1 AS " + alias,
@@ -143,6 +208,14 @@ func (b *QueryBuilder) Offset(offset int64) *QueryBuilder {
 	return b
 }
 
+// BindPagination renders the limit and offset as bindvars rather than
+// literals, so that the query text keeps the same for different pages,
+// which is friendly to the prepared statement and plan caches.
+func (b *QueryBuilder) BindPagination() *QueryBuilder {
+	b.bindPagination = true
+	return b
+}
+
 // GroupBy set the sorting order.
 func (b *QueryBuilder) GroupBy(column *sqls.TableColumn, args ...any) *QueryBuilder {
 	b.groupbys.AppendSegments(&sqls.Segment{
@@ -156,11 +229,22 @@ func (b *QueryBuilder) GroupBy(column *sqls.TableColumn, args ...any) *QueryBuil
 // Union unions other query builders, the type of query builders can be
 // *QueryBuilder or any other extended *QueryBuilder types (structs with
 // *QueryBuilder embedded.)
+//
+// The ORDER BY and LIMIT of b are rendered inside the first arm, use
+// CompoundBuilder to apply them to the combined result, or to combine
+// with other operators like UNION ALL, INTERSECT and EXCEPT.
 func (b *QueryBuilder) Union(builders ...sqls.Builder) *QueryBuilder {
 	b.unions = append(b.unions, builders...)
 	return b
 }
 
+// Dialect set the SQL dialect, which decides how the dialect-specific
+// clauses are rendered, e.g. the lateral joins.
+func (b *QueryBuilder) Dialect(d Dialect) *QueryBuilder {
+	b.dialect = d
+	return b
+}
+
 // BindVar set the bindvar style.
 func (b *QueryBuilder) BindVar(style syntax.BindVarStyle) *QueryBuilder {
 	b.bindVarStyle = style
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/sqlfmt"
 	"github.com/qjebbs/go-sqls/util"
 )
 
@@ -26,11 +27,40 @@ func (b *QueryBuilder) BuildContext(ctx *sqls.Context) (query string, err error)
 	return b.buildInternal(ctx)
 }
 
-// Debug enables debug mode.
+// Debug enables debug mode, which logs the interpolated query on building.
 func (b *QueryBuilder) Debug() {
 	b.debug = true
 }
 
+// DebugPretty is like Debug, but the logged query is formatted by
+// sqlfmt.Format to be more readable.
+func (b *QueryBuilder) DebugPretty() {
+	b.debug = true
+	b.debugPretty = true
+}
+
+// scoped returns a copy of b for a single build, in which the subqueries
+// are bound to the tables of the copy, so that building never modifies b
+// or the builders passed in by the caller.
+func (b *QueryBuilder) scoped() *QueryBuilder {
+	c := *b
+	c.fromTables = b.fromTables.clone()
+	c.fromTables.applyDialect(b.dialect)
+	c.conditions = withOuter(b.conditions, &c.fromTables)
+	c.havings = withOuter(b.havings, &c.fromTables)
+	for _, t := range c.tables {
+		from, ok := c.froms[t]
+		if !ok || !from.Lateral {
+			continue
+		}
+		lateral := *from
+		lateral.Segment = withOuter(from.Segment, &c.fromTables)
+		lateral.Source = withOuter(from.Source, &c.fromTables)
+		c.froms[t] = &lateral
+	}
+	return &c
+}
+
 // buildInternal builds the query with the selects.
 func (b *QueryBuilder) buildInternal(ctx *sqls.Context) (string, error) {
 	if b == nil {
@@ -39,6 +69,13 @@ func (b *QueryBuilder) buildInternal(ctx *sqls.Context) (string, error) {
 	if err := b.anyError(); err != nil {
 		return "", err
 	}
+	b = b.scoped()
+	if err := b.resolveJoins(); err != nil {
+		return "", err
+	}
+	if err := b.checkColumns(b.columnSegments()...); err != nil {
+		return "", err
+	}
 	clauses := make([]string, 0)
 
 	dep, err := b.calcDependency()
@@ -66,7 +103,11 @@ func (b *QueryBuilder) buildInternal(ctx *sqls.Context) (string, error) {
 	if from != "" {
 		clauses = append(clauses, from)
 	}
-	where, err := b.conditions.BuildContext(ctx)
+	conditions, err := b.keysetConditions()
+	if err != nil {
+		return "", err
+	}
+	where, err := conditions.BuildContext(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -80,18 +121,46 @@ func (b *QueryBuilder) buildInternal(ctx *sqls.Context) (string, error) {
 	if groupby != "" {
 		clauses = append(clauses, groupby)
 	}
-	order, err := b.orders.BuildContext(ctx)
+	having, err := b.havings.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if having != "" {
+		clauses = append(clauses, having)
+	}
+	window, err := b.windows.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if window != "" {
+		clauses = append(clauses, window)
+	}
+	order, err := b.orderSegment().BuildContext(ctx)
 	if err != nil {
 		return "", err
 	}
 	if order != "" {
 		clauses = append(clauses, order)
 	}
-	if b.limit > 0 {
-		clauses = append(clauses, fmt.Sprintf(`LIMIT %d`, b.limit))
+	if !b.useTop() {
+		pagination, err := paginationSegment(b.dialect, b.limit, b.offset, order != "", b.bindPagination)
+		if err != nil {
+			return "", err
+		}
+		page, err := pagination.BuildContext(ctx)
+		if err != nil {
+			return "", err
+		}
+		if page != "" {
+			clauses = append(clauses, page)
+		}
 	}
-	if b.offset > 0 {
-		clauses = append(clauses, fmt.Sprintf(`OFFSET %d`, b.offset))
+	lock, err := b.buildLocks()
+	if err != nil {
+		return "", err
+	}
+	if lock != "" {
+		clauses = append(clauses, lock)
 	}
 	query := strings.TrimSpace(strings.Join(clauses, " "))
 	if len(b.unions) > 0 {
@@ -106,44 +175,30 @@ func (b *QueryBuilder) buildInternal(ctx *sqls.Context) (string, error) {
 		if err != nil {
 			log.Printf("debug: interpolated query: %s\n", err)
 		}
+		if b.debugPretty {
+			interpolated = sqlfmt.Format(interpolated)
+		}
 		log.Println(interpolated)
 	}
 	return query, nil
 }
 
-func (b *QueryBuilder) buildCTEs(ctx *sqls.Context, dep map[Table]bool) (string, error) {
-	if len(b.ctes) == 0 {
-		return "", nil
+func (b *QueryBuilder) buildSelects(ctx *sqls.Context) (string, error) {
+	if len(b.distinctOn.Columns) > 0 {
+		return b.buildDistinctOnSelects(ctx)
 	}
-	clauses := make([]string, 0, len(b.ctes))
-	for _, cte := range b.ctes {
-		if !dep[cte.table] {
-			continue
-		}
-		query, err := cte.BuildContext(ctx)
+	prefix := "SELECT"
+	if b.distinct {
+		prefix = "SELECT DISTINCT"
+	}
+	if b.useTop() {
+		top, err := topSegment(b.limit, b.bindPagination).BuildContext(ctx)
 		if err != nil {
-			return "", fmt.Errorf("build CTE '%s': %w", cte.table, err)
-		}
-		if query == "" {
-			continue
+			return "", err
 		}
-		clauses = append(clauses, fmt.Sprintf(
-			"%s AS (%s)",
-			cte.table.Name, query,
-		))
-	}
-	if len(clauses) == 0 {
-		return "", nil
-	}
-	return "With " + strings.Join(clauses, ", "), nil
-}
-
-func (b *QueryBuilder) buildSelects(ctx *sqls.Context) (string, error) {
-	if b.distinct {
-		b.selects.Prefix = "SELECT DISTINCT"
-	} else {
-		b.selects.Prefix = "SELECT"
+		prefix += " " + top
 	}
+	b.selects.Prefix = prefix
 	sel, err := b.selects.BuildContext(ctx)
 	if err != nil {
 		return "", err
@@ -161,21 +216,142 @@ func (b *QueryBuilder) buildSelects(ctx *sqls.Context) (string, error) {
 	return sel + ", " + touches, nil
 }
 
+// useTop reports whether to limit the rows with "TOP (n)" of SQL Server.
+func (b *QueryBuilder) useTop() bool {
+	return b.dialect == DialectSQLServer && b.limit > 0 && b.offset <= 0
+}
+
+// buildDistinctOnSelects builds "SELECT DISTINCT ON (...) ...". The touched
+// order columns are not selected, since the ORDER BY references the columns
+// directly, see distinctOnOrders().
+func (b *QueryBuilder) buildDistinctOnSelects(ctx *sqls.Context) (string, error) {
+	if b.dialect != DialectPostgreSQL {
+		return "", fmt.Errorf("DISTINCT ON is not supported by %s", b.dialect)
+	}
+	if err := b.checkDistinctOnOrders(); err != nil {
+		return "", err
+	}
+	on, err := b.distinctOn.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	b.selects.Prefix = "SELECT " + on
+	sel, err := b.selects.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if sel == "" {
+		return "", fmt.Errorf("no columns selected")
+	}
+	return sel, nil
+}
+
+// checkDistinctOnOrders checks the leading ORDER BY columns match the
+// DISTINCT ON columns, in any order. The columns are compared by their
+// built text.
+func (b *QueryBuilder) checkDistinctOnOrders() error {
+	on := b.distinctOn.Columns
+	if len(b.sorts) == 0 {
+		return nil
+	}
+	if len(b.sorts) < len(on) {
+		return fmt.Errorf("DISTINCT ON expressions must match the leading ORDER BY expressions")
+	}
+	onSet, err := columnSet(on)
+	if err != nil {
+		return err
+	}
+	leading := make([]*sqls.TableColumn, 0, len(on))
+	for _, s := range b.sorts[:len(on)] {
+		leading = append(leading, s.column)
+	}
+	orderSet, err := columnSet(leading)
+	if err != nil {
+		return err
+	}
+	if len(orderSet) != len(onSet) {
+		return fmt.Errorf("DISTINCT ON expressions must match the leading ORDER BY expressions")
+	}
+	for c := range orderSet {
+		if !onSet[c] {
+			return fmt.Errorf("DISTINCT ON expressions must match the leading ORDER BY expressions")
+		}
+	}
+	return nil
+}
+
+// columnSet returns the set of the built columns, the args of which are
+// included to tell the expressions apart.
+func columnSet(columns []*sqls.TableColumn) (map[string]bool, error) {
+	set := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		query, args, err := (&sqls.Segment{
+			Raw:     "#c1",
+			Columns: []*sqls.TableColumn{c},
+		}).Build()
+		if err != nil {
+			return nil, err
+		}
+		set[fmt.Sprintf("%s %v", query, args)] = true
+	}
+	return set, nil
+}
+
+// orderSegment returns the ORDER BY segment. For DISTINCT ON, it references
+// the columns directly rather than the touched aliases, otherwise PostgreSQL
+// cannot match them with the DISTINCT ON expressions. For Before(), the
+// orders are reversed.
+func (b *QueryBuilder) orderSegment() *sqls.Segment {
+	distinctOn := len(b.distinctOn.Columns) > 0
+	reversed := b.keyset != nil && b.keyset.before
+	if !distinctOn && !reversed {
+		return b.orders
+	}
+	orderBy := &sqls.Segment{
+		Prefix: b.orders.Prefix,
+		Raw:    b.orders.Raw,
+	}
+	for i, s := range b.sorts {
+		order := s.order
+		if reversed {
+			order = order.reverse()
+		}
+		if distinctOn {
+			orderBy.AppendSegments(&sqls.Segment{
+				Raw:     "#c1 " + orders[order],
+				Columns: []*sqls.TableColumn{s.column},
+			})
+			continue
+		}
+		orderBy.AppendSegments(&sqls.Segment{
+			Raw: fmt.Sprintf("_order_%d %s", i+1, orders[order]),
+		})
+	}
+	return orderBy
+}
+
 func (b *QueryBuilder) buildFrom(ctx *sqls.Context, dep map[Table]bool) (string, error) {
 	tables := make([]string, 0, len(b.tables))
 	for _, t := range b.tables {
 		ft, ok := b.froms[t]
 		if !ok {
 			// should not happen
-			return "", fmt.Errorf("table '%s' not found", t)
+			return "", fmt.Errorf("table '%s' not found", t.AppliedName())
 		}
-		if b.distinct && ft.Optional && !dep[t] {
+		if b.trimmed(t, dep) {
 			continue
 		}
-		from := b.froms[t]
-		c, err := from.Segment.BuildContext(ctx)
+		seg := ft.Segment
+		if ft.Lateral {
+			var err error
+			seg, err = b.lateralSegment(ft)
+			if err != nil {
+				return "", err
+			}
+		}
+		c, err := seg.BuildContext(ctx)
 		if err != nil {
-			return "", fmt.Errorf("build FROM '%s': %w", from.Segment.Raw, err)
+			return "", fmt.Errorf("build FROM '%s': %w", seg.Raw, err)
 		}
 		tables = append(tables, c)
 	}
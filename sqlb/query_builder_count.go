@@ -0,0 +1,116 @@
+package sqlb
+
+import (
+	"fmt"
+
+	"github.com/qjebbs/go-sqls"
+)
+
+// CountBuilder returns a derived builder which counts the rows of the
+// query, without ORDER BY, LIMIT, OFFSET, locking and the keyset cursor,
+// so that the list endpoints can get the total from the same base builder.
+//
+// The optional pk is the key which identifies the rows of a DISTINCT query,
+// e.g. users.Column("id"). The derived query is chosen as:
+//
+//   - SELECT COUNT(*) FROM ... for the plain query
+//   - SELECT COUNT(DISTINCT pk) FROM ... for the DISTINCT query with pk
+//   - SELECT COUNT(*) FROM (...) AS list for the others, e.g. GROUP BY,
+//     UNION, DISTINCT ON or DISTINCT without pk
+//
+// The optional joins are trimmed as the query does, i.e. for the unique
+// joins and the DISTINCT query, if only the dropped clauses reference them.
+// The derived builder and b can be modified independently.
+func (b *QueryBuilder) CountBuilder(pk ...*sqls.TableColumn) *QueryBuilder {
+	c := b.derive()
+	if len(pk) > 1 {
+		c.pushError(fmt.Errorf("count: at most one pk is allowed, got %d", len(pk)))
+		return c
+	}
+	var key *sqls.TableColumn
+	if len(pk) == 1 {
+		key = pk[0]
+	}
+	grouped := len(b.groupbys.Segments) > 0 || len(b.unions) > 0
+	distinctOn := len(b.distinctOn.Columns) > 0
+	switch {
+	case !grouped && !b.isDistinct():
+		c.selects.WithColumns(Func("COUNT(*)"))
+		return c
+	case !grouped && !distinctOn && key != nil:
+		c.distinct = false
+		c.countDistinct = true
+		c.selects.WithColumns(Func("COUNT(DISTINCT #c1)", key))
+		return c
+	case distinctOn:
+		// the rows of DISTINCT ON are as many as the distinct keys
+		c.distinct = true
+		c.distinctOn = &sqls.Segment{
+			Prefix: b.distinctOn.Prefix,
+			Raw:    b.distinctOn.Raw,
+		}
+		c.selects.WithColumns(b.distinctOn.Columns...)
+	case !b.distinct && len(b.unions) == 0:
+		// the groups, regardless of the selected columns
+		c.selects.WithColumns(Func("1"))
+	default:
+		c.selects.WithColumns(b.selects.Columns...)
+	}
+	// CTEs are not allowed in subqueries by some databases
+	ctes := c.ctes
+	c.ctes = nil
+	outer := NewQueryBuilder().
+		BindVar(b.bindVarStyle).
+		Dialect(b.dialect).
+		Select(Func("COUNT(*)")).
+		FromQuery(c, "list")
+	outer.ctes = ctes
+	return outer
+}
+
+// derive returns a copy of b without the selects and the clauses which
+// don't change the number of rows.
+func (b *QueryBuilder) derive() *QueryBuilder {
+	fresh := NewQueryBuilder()
+	c := *b
+	c.errorList = errorList{errors: append([]error(nil), b.errors...)}
+	// the joins must be resolved with all the clauses, into a copy of the
+	// tables, so that b is not modified.
+	c.fromTables = b.fromTables.clone()
+	if err := c.resolveJoins(); err != nil {
+		c.pushError(err)
+	}
+	c.conditions = copySegment(b.conditions)
+	c.groupbys = copySegment(b.groupbys)
+	c.havings = copySegment(b.havings)
+	c.distinctOn = copySegment(b.distinctOn)
+	c.ctes = append([]*cte(nil), b.ctes...)
+	c.unions = append([]sqls.Builder(nil), b.unions...)
+	c.selects = fresh.selects
+	c.touches = fresh.touches
+	c.orders = fresh.orders
+	c.windows = fresh.windows
+	c.sorts = nil
+	c.limit = 0
+	c.offset = 0
+	c.locks = nil
+	c.keyset = nil
+	c.debug = false
+	c.debugPretty = false
+	return &c
+}
+
+// copySegment returns a copy of s, which can be appended to without
+// affecting s.
+func copySegment(s *sqls.Segment) *sqls.Segment {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.Args = append([]any(nil), s.Args...)
+	c.Columns = append([]*sqls.TableColumn(nil), s.Columns...)
+	c.Tables = append([]sqls.Table(nil), s.Tables...)
+	c.Segments = append([]*sqls.Segment(nil), s.Segments...)
+	c.Builders = append([]sqls.Builder(nil), s.Builders...)
+	return &c
+}
@@ -7,53 +7,85 @@ import (
 )
 
 func (b *QueryBuilder) calcDependency() (map[Table]bool, error) {
-	tables := extractTables(
+	m, err := b.dependencies(
 		b.selects,
+		b.distinctOn,
 		b.touches,
 		b.conditions,
 		b.orders,
 		b.groupbys,
+		b.havings,
+		b.windows,
 	)
-	m := make(map[Table]bool)
-	// first table is the main table and always included
-	m[b.tables[0]] = true
-	for _, t := range tables {
-		err := b.markDependencies(m, t.Table)
-		if err != nil {
-			return nil, err
+	if err != nil {
+		return nil, err
+	}
+	if !b.isDistinct() {
+		// the optional joins which are not unique are kept without
+		// DISTINCT, so are the tables they depend on.
+		for _, t := range b.tables[1:] {
+			if from := b.froms[t]; from.Optional && !from.Unique {
+				if err := b.markDependencies(m, t.AppliedName()); err != nil {
+					return nil, err
+				}
+			}
 		}
 	}
+	if err := b.markLockDependencies(m); err != nil {
+		return nil, err
+	}
+	if err := b.markSubqueries(m, b.conditions, b.havings); err != nil {
+		return nil, err
+	}
 	// mark for CTEs
 	for _, t := range b.tables {
-		if b.distinct && b.froms[t].Optional && !m[t] {
+		if b.trimmed(t, m) {
 			continue
 		}
 		// this could probably mark a CTE table that does not exists, but do no harm.
 		m[NewTable(t.Name, "")] = true
+		// the subqueries could reference CTEs too
+		if from := b.froms[t]; from.Source != nil {
+			for _, name := range referencedNames(from.Source.Builders...) {
+				m[NewTable(name, "")] = true
+			}
+		}
 	}
 	return m, nil
 }
 
-func (b *QueryBuilder) markDependencies(dep map[Table]bool, t sqls.Table) error {
-	ta, ok := b.appliedNames[t]
-	if !ok {
-		return fmt.Errorf("table not found: '%s'", t)
-	}
-	from, ok := b.froms[ta]
-	if !ok {
-		return fmt.Errorf("from undefined: '%s'", t)
+// trimmed reports whether the table is an optional join that is not
+// required. It's trimmed if the join is unique, or the query is DISTINCT,
+// either of which makes sure the trimming doesn't change the result.
+func (b *QueryBuilder) trimmed(t Table, dep map[Table]bool) bool {
+	from := b.froms[t]
+	if !from.Optional || dep[t] {
+		return false
 	}
-	if dep[ta] {
-		return nil
-	}
-	dep[ta] = true
-	for _, ft := range extractTables(from.Segment) {
-		if ft.Table == t {
+	return from.Unique || b.isDistinct()
+}
+
+// markSubqueries marks the tables of b referenced by the subqueries in the
+// segments as required, e.g. the correlated subquery of WhereExists(). The
+// subqueries are bound to the tables of b by scoped().
+func (b *QueryBuilder) markSubqueries(dep map[Table]bool, segments ...*sqls.Segment) error {
+	for _, s := range segments {
+		if s == nil {
 			continue
 		}
-		err := b.markDependencies(dep, ft.Table)
-		if err != nil {
-			return fmt.Errorf("%s: %s", ft.Source, err)
+		for _, builder := range s.Builders {
+			q, ok := builder.(*QueryBuilder)
+			if !ok {
+				continue
+			}
+			for _, t := range outerReferences(q) {
+				if err := b.markDependencies(dep, t.Table); err != nil {
+					return fmt.Errorf("%s: %w", t.Source, err)
+				}
+			}
+		}
+		if err := b.markSubqueries(dep, s.Segments...); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -86,16 +118,25 @@ func extractTables2(segments []*sqls.Segment, tables *[]*tableWithSouce, dict *m
 			})
 			(*dict)[t] = true
 		}
-		for i, c := range s.Columns {
-			if c == nil || (*dict)[c.Table] {
-				continue
-			}
+		extractColumnTables(s.Columns, s.Raw, tables, dict)
+		extractTables2(s.Segments, tables, dict)
+	}
+}
+
+func extractColumnTables(columns []*sqls.TableColumn, raw string, tables *[]*tableWithSouce, dict *map[sqls.Table]bool) {
+	for i, c := range columns {
+		if c == nil {
+			continue
+		}
+		// an expression like a window function may have no table of its
+		// own, but only the tables of the nested columns.
+		if c.Table != "" && !(*dict)[c.Table] {
 			*tables = append(*tables, &tableWithSouce{
 				Table:  c.Table,
-				Source: fmt.Sprintf("#column%d '%s' of '%s'", i+1, c.Raw, s.Raw),
+				Source: fmt.Sprintf("#column%d '%s' of '%s'", i+1, c.Raw, raw),
 			})
 			(*dict)[c.Table] = true
 		}
-		extractTables2(s.Segments, tables, dict)
+		extractColumnTables(c.Columns, c.Raw, tables, dict)
 	}
 }
@@ -1,44 +1,37 @@
 package sqlb
 
-import (
-	"fmt"
-
-	"github.com/qjebbs/go-sqls"
-)
+import "github.com/qjebbs/go-sqls"
 
 // From set the from table.
 func (b *QueryBuilder) From(t Table) *QueryBuilder {
-	if t.Name == "" {
-		b.pushError(fmt.Errorf("from table is empty"))
-		return b
-	}
-	tableAndAlias := string(t.Name)
-	if t.Alias != "" {
-		tableAndAlias = tableAndAlias + " AS " + string(t.Alias)
+	if err := b.setFrom(t); err != nil {
+		b.pushError(err)
 	}
-	if len(b.tables) == 0 {
-		b.tables = append(b.tables, t)
-	} else {
-		b.tables[0] = t
-	}
-	b.appliedNames[t.AppliedName()] = t
-	b.froms[t] = &fromTable{
-		Segment: &sqls.Segment{
-			Raw: tableAndAlias,
-		},
-		Optional: false,
+	return b
+}
+
+// FromQuery set the from table as a subquery with alias, e.g.:
+//
+//	b.FromQuery(sqlb.NewQueryBuilder().Select(...).From(...), "t")
+//	// FROM (SELECT ...) AS t
+//
+// The alias takes part in the dependency calculation like a table, use
+// sqlb.NewTable("", alias) to reference its columns.
+func (b *QueryBuilder) FromQuery(builder sqls.Builder, alias sqls.Table) *QueryBuilder {
+	if err := b.setFromQuery(builder, alias); err != nil {
+		b.pushError(err)
 	}
 	return b
 }
 
 // InnerJoin append a inner join table.
 func (b *QueryBuilder) InnerJoin(t Table, on *sqls.Segment) *QueryBuilder {
-	return b.join("INNER JOIN", t, on, false)
+	return b.join("INNER JOIN", t, on, false, false)
 }
 
 // LeftJoin append / replace a left join table.
 func (b *QueryBuilder) LeftJoin(t Table, on *sqls.Segment) *QueryBuilder {
-	return b.join("LEFT JOIN", t, on, false)
+	return b.join("LEFT JOIN", t, on, false, false)
 }
 
 // LeftJoinOptional append / replace a left join table, and mark it as optional.
@@ -49,7 +42,8 @@ func (b *QueryBuilder) LeftJoin(t Table, on *sqls.Segment) *QueryBuilder {
 //     *sqls.Segment.Columns, so that the *QueryBuilder can calculate the dependency
 //     correctly.
 //   - Make sure it's used with the SELECT DISTINCT statement, otherwise it works
-//     exactly the same as LeftJoin().
+//     exactly the same as LeftJoin(). For the joins that match at most one
+//     row, use LeftJoinUnique() instead.
 //
 // Consider the following two queries:
 //
@@ -60,65 +54,74 @@ func (b *QueryBuilder) LeftJoin(t Table, on *sqls.Segment) *QueryBuilder {
 // If the join to "bar" is declared with LeftJoinOptional(), *QueryBuilder
 // will trim it if no relative columns referenced in the query, aka Join Elimination.
 func (b *QueryBuilder) LeftJoinOptional(t Table, on *sqls.Segment) *QueryBuilder {
-	return b.join("LEFT JOIN", t, on, true)
+	return b.join("LEFT JOIN", t, on, true, false)
+}
+
+// LeftJoinUnique append a left join table, which matches at most one row
+// of each row of the query, e.g. a many-to-one lookup, and mark it as
+// optional. e.g.:
+//
+//	b.LeftJoinUnique(orgs, &sqls.Segment{
+//		Raw:     "#c1=#c2",
+//		Columns: []*sqls.TableColumn{orgs.Column("id"), users.Column("org_id")},
+//	})
+//
+// Unlike LeftJoinOptional(), it's trimmed whenever no relative columns
+// referenced in the query, even without SELECT DISTINCT, since dropping it
+// doesn't change the rows. Make sure the join condition matches a unique
+// key of the table, otherwise the result changes depending on whether the
+// join is trimmed.
+func (b *QueryBuilder) LeftJoinUnique(t Table, on *sqls.Segment) *QueryBuilder {
+	return b.join("LEFT JOIN", t, on, true, true)
 }
 
 // RightJoin append / replace a right join table.
 func (b *QueryBuilder) RightJoin(t Table, on *sqls.Segment) *QueryBuilder {
-	return b.join("RIGHT JOIN", t, on, false)
+	return b.join("RIGHT JOIN", t, on, false, false)
 }
 
 // FullJoin append / replace a full join table.
 func (b *QueryBuilder) FullJoin(t Table, on *sqls.Segment) *QueryBuilder {
-	return b.join("FULL JOIN", t, on, false)
+	return b.join("FULL JOIN", t, on, false, false)
 }
 
 // CrossJoin append / replace a cross join table.
 func (b *QueryBuilder) CrossJoin(t Table) *QueryBuilder {
-	return b.join("CROSS JOIN", t, nil, false)
+	return b.join("CROSS JOIN", t, nil, false, false)
 }
 
-// From append or replace a from table.
-func (b *QueryBuilder) join(joinStr string, t Table, on *sqls.Segment, optional bool) *QueryBuilder {
-	if t.Name == "" {
-		b.pushError(fmt.Errorf("join table name is empty"))
-		return b
-	}
-	if _, ok := b.froms[t]; ok {
-		if t.Alias == "" {
-			b.pushError(fmt.Errorf("table [%s] is already joined", t.Name))
-			return b
-		}
-		b.pushError(fmt.Errorf("table [%s AS %s] is already joined", t.Name, t.Alias))
-		return b
-	}
-	if len(b.tables) == 0 {
-		// reserve the first alias for the main table
-		b.tables = append(b.tables, Table{})
-	}
-	b.tables = append(b.tables, t)
-	b.appliedNames[t.AppliedName()] = t
-	tableAndAlias := t.Name
-	if t.Alias != "" {
-		tableAndAlias = tableAndAlias + " AS " + t.Alias
-	}
-	if on == nil || on.Raw == "" {
-		b.froms[t] = &fromTable{
-			Segment: &sqls.Segment{
-				Raw: fmt.Sprintf("%s %s", joinStr, tableAndAlias),
-			},
-			Optional: optional,
-		}
-		return b
+// InnerJoinQuery append a inner join subquery with alias.
+func (b *QueryBuilder) InnerJoinQuery(builder sqls.Builder, alias sqls.Table, on *sqls.Segment) *QueryBuilder {
+	return b.joinQuery("INNER JOIN", builder, alias, on, false, false)
+}
+
+// LeftJoinQuery append a left join subquery with alias.
+func (b *QueryBuilder) LeftJoinQuery(builder sqls.Builder, alias sqls.Table, on *sqls.Segment) *QueryBuilder {
+	return b.joinQuery("LEFT JOIN", builder, alias, on, false, false)
+}
+
+// LeftJoinOptionalQuery append a left join subquery with alias, and mark it
+// as optional, see LeftJoinOptional() for details.
+func (b *QueryBuilder) LeftJoinOptionalQuery(builder sqls.Builder, alias sqls.Table, on *sqls.Segment) *QueryBuilder {
+	return b.joinQuery("LEFT JOIN", builder, alias, on, true, false)
+}
+
+// LeftJoinUniqueQuery append a left join subquery with alias, which matches
+// at most one row, see LeftJoinUnique() for details.
+func (b *QueryBuilder) LeftJoinUniqueQuery(builder sqls.Builder, alias sqls.Table, on *sqls.Segment) *QueryBuilder {
+	return b.joinQuery("LEFT JOIN", builder, alias, on, true, true)
+}
+
+func (b *QueryBuilder) join(joinStr string, t Table, on *sqls.Segment, optional, unique bool) *QueryBuilder {
+	if err := b.fromTables.join(joinStr, t, on, optional, unique); err != nil {
+		b.pushError(err)
 	}
-	b.froms[t] = &fromTable{
-		Segment: &sqls.Segment{
-			Raw:      fmt.Sprintf("%s %s ON %s", joinStr, tableAndAlias, on.Raw),
-			Segments: on.Segments,
-			Columns:  on.Columns,
-			Args:     on.Args,
-		},
-		Optional: optional,
+	return b
+}
+
+func (b *QueryBuilder) joinQuery(joinStr string, builder sqls.Builder, alias sqls.Table, on *sqls.Segment, optional, unique bool) *QueryBuilder {
+	if err := b.fromTables.joinQuery(joinStr, builder, alias, on, optional, unique); err != nil {
+		b.pushError(err)
 	}
 	return b
 }
@@ -0,0 +1,189 @@
+package sqlb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qjebbs/go-sqls"
+)
+
+// keyset is the cursor of keyset pagination.
+type keyset struct {
+	values []any
+	before bool
+}
+
+// After limits the query to the rows after the cursor, aka keyset
+// pagination, which is much faster than Offset() for the deep pages. The
+// values are the ones of the ORDER BY columns of the last row in the
+// current page, and can be carried by an opaque token, see
+// codec.EncodeCursor(). e.g.:
+//
+//	b.OrderBy(users.Column("created_at"), sqlb.Desc).
+//		OrderBy(users.Column("id"), sqlb.Desc).
+//		After(lastCreatedAt, lastID).
+//		Limit(20)
+//	// WHERE (u.created_at, u.id) < ($1, $2) ORDER BY ... LIMIT 20
+//
+// The predicate respects the order and NULLS FIRST / LAST of each column.
+// A NULL value is allowed only for the column ordered with explicit NULLS
+// FIRST / LAST. To make the pagination stable, the last ORDER BY column
+// should be unique.
+func (b *QueryBuilder) After(values ...any) *QueryBuilder {
+	b.keyset = &keyset{values: values}
+	return b
+}
+
+// Before limits the query to the rows before the cursor, where the values
+// are the ones of the first row in the current page, see After().
+//
+// To get the rows right before the cursor, the ORDER BY is reversed, so
+// the rows are returned in reversed order, which should be reversed back
+// after scanning.
+func (b *QueryBuilder) Before(values ...any) *QueryBuilder {
+	b.keyset = &keyset{values: values, before: true}
+	return b
+}
+
+// keysetConditions returns the WHERE conditions with the keyset predicate
+// appended, without changing the conditions of the builder.
+func (b *QueryBuilder) keysetConditions() (*sqls.Segment, error) {
+	if b.keyset == nil {
+		return b.conditions, nil
+	}
+	predicate, err := b.keysetPredicate()
+	if err != nil {
+		return nil, err
+	}
+	conditions := &sqls.Segment{
+		Prefix: b.conditions.Prefix,
+		Raw:    b.conditions.Raw,
+	}
+	conditions.AppendSegments(b.conditions.Segments...)
+	conditions.AppendSegments(predicate)
+	return conditions, nil
+}
+
+// keysetPredicate returns the predicate of the rows after the cursor in
+// the order, where the orders are reversed for Before(). It uses the row
+// value comparison like "(c1, c2) > ($1, $2)" if possible, or the expanded
+// form like "(c1 > $1 OR (c1 = $1 AND c2 > $2))".
+func (b *QueryBuilder) keysetPredicate() (*sqls.Segment, error) {
+	if len(b.sorts) == 0 {
+		return nil, fmt.Errorf("keyset pagination requires ORDER BY")
+	}
+	if len(b.keyset.values) != len(b.sorts) {
+		return nil, fmt.Errorf("keyset cursor has %d values, want %d of ORDER BY columns", len(b.keyset.values), len(b.sorts))
+	}
+	s := &sqls.Segment{}
+	sorts := make([]*sortColumn, 0, len(b.sorts))
+	for _, sc := range b.sorts {
+		s.Columns = append(s.Columns, sc.column)
+		order := sc.order
+		if b.keyset.before {
+			order = order.reverse()
+		}
+		sorts = append(sorts, &sortColumn{column: sc.column, order: order})
+	}
+	// the bindvars of the values, empty for NULL
+	bindvars := make([]string, len(sorts))
+	for i, v := range b.keyset.values {
+		if v == nil {
+			if sorts[i].order == Asc || sorts[i].order == Desc {
+				return nil, fmt.Errorf("keyset cursor value %d is NULL, which requires NULLS FIRST / LAST in ORDER BY", i+1)
+			}
+			continue
+		}
+		s.Args = append(s.Args, v)
+		bindvars[i] = fmt.Sprintf("$%d", len(s.Args))
+	}
+	if b.rowValueKeyset(sorts) {
+		columns := make([]string, 0, len(sorts))
+		for i := range sorts {
+			columns = append(columns, fmt.Sprintf("#c%d", i+1))
+		}
+		s.Raw = fmt.Sprintf(
+			"(%s) %s (%s)",
+			strings.Join(columns, ", "),
+			keysetOperator(sorts[0].order),
+			strings.Join(bindvars, ", "),
+		)
+		return s, nil
+	}
+	terms := make([]string, 0, len(sorts))
+	equals := make([]string, 0, len(sorts))
+	for i, sc := range sorts {
+		column := fmt.Sprintf("#c%d", i+1)
+		if next := keysetNext(column, sc.order, bindvars[i]); next != "" {
+			terms = append(terms, strings.Join(append(equals, next), " AND "))
+		}
+		if bindvars[i] == "" {
+			equals = append(equals, column+" IS NULL")
+		} else {
+			equals = append(equals, column+" = "+bindvars[i])
+		}
+	}
+	switch len(terms) {
+	case 0:
+		// the cursor is at the end
+		s.Raw = "1=0"
+		s.Columns = nil
+		s.Args = nil
+	case 1:
+		s.Raw = terms[0]
+	default:
+		for i, term := range terms {
+			if strings.Contains(term, " AND ") {
+				terms[i] = "(" + term + ")"
+			}
+		}
+		s.Raw = "(" + strings.Join(terms, " OR ") + ")"
+	}
+	return s, nil
+}
+
+// rowValueKeyset reports whether the row value comparison can be used,
+// which requires all columns in the same order without explicit NULLS
+// FIRST / LAST.
+func (b *QueryBuilder) rowValueKeyset(sorts []*sortColumn) bool {
+	if len(sorts) < 2 {
+		return false
+	}
+	switch b.dialect {
+	case DialectPostgreSQL, DialectMySQL, DialectSQLite:
+	default:
+		return false
+	}
+	for _, sc := range sorts {
+		if sc.order != sorts[0].order || (sc.order != Asc && sc.order != Desc) {
+			return false
+		}
+	}
+	return true
+}
+
+func keysetOperator(order Order) string {
+	if order >= Desc {
+		return "<"
+	}
+	return ">"
+}
+
+// keysetNext returns the predicate of the column values after the bindvar
+// in the order, or empty if there is none. An empty bindvar means NULL.
+func keysetNext(column string, order Order, bindvar string) string {
+	nullsFirst := order == AscNullsFirst || order == DescNullsFirst
+	nullsLast := order == AscNullsLast || order == DescNullsLast
+	if bindvar == "" {
+		if nullsFirst {
+			return column + " IS NOT NULL"
+		}
+		// nothing after NULLs
+		return ""
+	}
+	next := column + " " + keysetOperator(order) + " " + bindvar
+	if nullsLast {
+		return "(" + next + " OR " + column + " IS NULL)"
+	}
+	return next
+}
@@ -0,0 +1,156 @@
+package sqlb
+
+import (
+	"fmt"
+
+	"github.com/qjebbs/go-sqls"
+)
+
+// LeftJoinLateral append a left join of lateral subquery with alias, the
+// subquery can reference the columns of the preceding tables. The on is
+// optional, it's rendered according to the dialect:
+//
+//   - PostgreSQL, MySQL: LEFT JOIN LATERAL (SELECT ...) AS x ON TRUE
+//   - SQL Server, Oracle: OUTER APPLY (SELECT ...) AS x
+//
+// The outer tables referenced by the subquery are never trimmed while the
+// lateral join is kept, which is figured out from the columns of the
+// subquery when it's a *QueryBuilder or *sqls.Segment.
+func (b *QueryBuilder) LeftJoinLateral(builder sqls.Builder, alias sqls.Table, on *sqls.Segment) *QueryBuilder {
+	return b.joinLateral("LEFT JOIN", builder, alias, on)
+}
+
+// InnerJoinLateral append a inner join of lateral subquery with alias, see
+// LeftJoinLateral() for details. It's rendered as:
+//
+//   - PostgreSQL, MySQL: CROSS JOIN LATERAL (SELECT ...) AS x, or
+//     INNER JOIN LATERAL (SELECT ...) AS x ON ... if on is specified
+//   - SQL Server, Oracle: CROSS APPLY (SELECT ...) AS x
+func (b *QueryBuilder) InnerJoinLateral(builder sqls.Builder, alias sqls.Table, on *sqls.Segment) *QueryBuilder {
+	return b.joinLateral("INNER JOIN", builder, alias, on)
+}
+
+func (b *QueryBuilder) joinLateral(joinStr string, builder sqls.Builder, alias sqls.Table, on *sqls.Segment) *QueryBuilder {
+	if err := b.fromTables.joinQuery(joinStr, builder, alias, on, false, false); err != nil {
+		b.pushError(err)
+		return b
+	}
+	b.froms[NewTable("", alias)].Lateral = true
+	return b
+}
+
+// withOuter returns a copy of the segment, in which the *QueryBuilder
+// subqueries are replaced by their copies with the outer tables visible,
+// so that the builders passed in by the caller are never modified, and
+// they can still be reused or built on their own.
+func withOuter(s *sqls.Segment, outer *fromTables) *sqls.Segment {
+	if s == nil || !hasSubquery(s) {
+		return s
+	}
+	c := *s
+	c.Segments = make([]*sqls.Segment, 0, len(s.Segments))
+	for _, sub := range s.Segments {
+		c.Segments = append(c.Segments, withOuter(sub, outer))
+	}
+	c.Builders = make([]sqls.Builder, 0, len(s.Builders))
+	for _, builder := range s.Builders {
+		if q, ok := builder.(*QueryBuilder); ok {
+			scoped := *q
+			scoped.outer = outer
+			builder = &scoped
+		}
+		c.Builders = append(c.Builders, builder)
+	}
+	return &c
+}
+
+// hasSubquery tells if the segment tree has any *QueryBuilder.
+func hasSubquery(s *sqls.Segment) bool {
+	if s == nil {
+		return false
+	}
+	for _, builder := range s.Builders {
+		if _, ok := builder.(*QueryBuilder); ok {
+			return true
+		}
+	}
+	for _, sub := range s.Segments {
+		if hasSubquery(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// lateralSegment returns the segment of the lateral join for the dialect.
+func (b *QueryBuilder) lateralSegment(from *fromTable) (*sqls.Segment, error) {
+	hasOn := from.On != nil && from.On.Raw != ""
+	switch b.dialect {
+	case DialectPostgreSQL, DialectMySQL:
+		if hasOn {
+			return &sqls.Segment{
+				Raw:      from.Join + " LATERAL #s1 ON #s2",
+				Segments: []*sqls.Segment{from.Source, from.On},
+			}, nil
+		}
+		if from.Join == "INNER JOIN" {
+			return &sqls.Segment{
+				Raw:      "CROSS JOIN LATERAL #s1",
+				Segments: []*sqls.Segment{from.Source},
+			}, nil
+		}
+		return &sqls.Segment{
+			Raw:      from.Join + " LATERAL #s1 ON TRUE",
+			Segments: []*sqls.Segment{from.Source},
+		}, nil
+	case DialectSQLServer, DialectOracle:
+		if hasOn {
+			return nil, fmt.Errorf("join condition of lateral join is not supported by %s, move it into the subquery", b.dialect)
+		}
+		apply := "CROSS APPLY"
+		if from.Join == "LEFT JOIN" {
+			apply = "OUTER APPLY"
+		}
+		return &sqls.Segment{
+			Raw:      apply + " #s1",
+			Segments: []*sqls.Segment{from.Source},
+		}, nil
+	default:
+		return nil, fmt.Errorf("lateral join is not supported by %s", b.dialect)
+	}
+}
+
+// outerReferences returns the tables referenced by the builder but not
+// declared in it, which are the outer tables for a lateral subquery.
+func outerReferences(builder sqls.Builder) []*tableWithSouce {
+	switch b := builder.(type) {
+	case *QueryBuilder:
+		segments := []*sqls.Segment{
+			b.selects,
+			b.distinctOn,
+			b.touches,
+			b.conditions,
+			b.orders,
+			b.groupbys,
+			b.havings,
+			b.windows,
+		}
+		for _, t := range b.tables {
+			if from, ok := b.froms[t]; ok {
+				segments = append(segments, from.Segment)
+			}
+		}
+		tables := make([]*tableWithSouce, 0)
+		for _, t := range extractTables(segments...) {
+			if _, ok := b.appliedNames[t.Table]; ok {
+				continue
+			}
+			tables = append(tables, t)
+		}
+		return tables
+	case *sqls.Segment:
+		return extractTables(b)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,125 @@
+package sqlb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lockClause is a row locking clause like "FOR UPDATE OF t SKIP LOCKED".
+type lockClause struct {
+	strength string  // UPDATE, NO KEY UPDATE, SHARE
+	of       []Table // tables to lock
+	wait     string  // SKIP LOCKED, NOWAIT
+}
+
+// ForUpdate appends a FOR UPDATE locking clause, which can be modified by
+// the following Of(), SkipLocked() and NoWait(). e.g.:
+//
+//	b.ForUpdate().Of(jobs).SkipLocked()
+//	// SELECT ... LIMIT 10 FOR UPDATE OF j SKIP LOCKED
+//
+// Locking is not allowed with DISTINCT, GROUP BY or UNION.
+func (b *QueryBuilder) ForUpdate() *QueryBuilder {
+	return b.lock("UPDATE")
+}
+
+// ForNoKeyUpdate appends a FOR NO KEY UPDATE locking clause, which is
+// supported by PostgreSQL only. See ForUpdate() for details.
+func (b *QueryBuilder) ForNoKeyUpdate() *QueryBuilder {
+	return b.lock("NO KEY UPDATE")
+}
+
+// ForShare appends a FOR SHARE locking clause. See ForUpdate() for details.
+func (b *QueryBuilder) ForShare() *QueryBuilder {
+	return b.lock("SHARE")
+}
+
+func (b *QueryBuilder) lock(strength string) *QueryBuilder {
+	b.locks = append(b.locks, &lockClause{strength: strength})
+	return b
+}
+
+// Of limits the last locking clause to the tables.
+func (b *QueryBuilder) Of(tables ...Table) *QueryBuilder {
+	l := b.lastLock("Of")
+	if l == nil {
+		return b
+	}
+	l.of = append(l.of, tables...)
+	return b
+}
+
+// SkipLocked makes the last locking clause skip the rows that cannot be
+// locked immediately.
+func (b *QueryBuilder) SkipLocked() *QueryBuilder {
+	if l := b.lastLock("SkipLocked"); l != nil {
+		l.wait = "SKIP LOCKED"
+	}
+	return b
+}
+
+// NoWait makes the last locking clause report an error rather than wait
+// for the rows that cannot be locked immediately.
+func (b *QueryBuilder) NoWait() *QueryBuilder {
+	if l := b.lastLock("NoWait"); l != nil {
+		l.wait = "NOWAIT"
+	}
+	return b
+}
+
+func (b *QueryBuilder) lastLock(method string) *lockClause {
+	if len(b.locks) == 0 {
+		b.pushError(fmt.Errorf("%s: no locking clause, call ForUpdate() or ForShare() first", method))
+		return nil
+	}
+	return b.locks[len(b.locks)-1]
+}
+
+// markLockDependencies marks the tables to lock as required, so that
+// they are not trimmed as optional joins.
+func (b *QueryBuilder) markLockDependencies(dep map[Table]bool) error {
+	for _, l := range b.locks {
+		for _, t := range l.of {
+			if err := b.markDependencies(dep, t.AppliedName()); err != nil {
+				return fmt.Errorf("FOR %s OF: %w", l.strength, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (b *QueryBuilder) buildLocks() (string, error) {
+	if len(b.locks) == 0 {
+		return "", nil
+	}
+	switch {
+	case b.isDistinct():
+		return "", fmt.Errorf("locking is not allowed with DISTINCT")
+	case len(b.groupbys.Segments) > 0:
+		return "", fmt.Errorf("locking is not allowed with GROUP BY")
+	case len(b.unions) > 0:
+		return "", fmt.Errorf("locking is not allowed with UNION")
+	}
+	if b.dialect != DialectPostgreSQL && b.dialect != DialectMySQL {
+		return "", fmt.Errorf("row locking is not supported by %s", b.dialect)
+	}
+	clauses := make([]string, 0, len(b.locks))
+	for _, l := range b.locks {
+		if l.strength == "NO KEY UPDATE" && b.dialect != DialectPostgreSQL {
+			return "", fmt.Errorf("FOR NO KEY UPDATE is not supported by %s", b.dialect)
+		}
+		clause := "FOR " + l.strength
+		if len(l.of) > 0 {
+			names := make([]string, 0, len(l.of))
+			for _, t := range l.of {
+				names = append(names, string(t.AppliedName()))
+			}
+			clause += " OF " + strings.Join(names, ", ")
+		}
+		if l.wait != "" {
+			clause += " " + l.wait
+		}
+		clauses = append(clauses, clause)
+	}
+	return strings.Join(clauses, " "), nil
+}
@@ -0,0 +1,154 @@
+package sqlb
+
+import (
+	"fmt"
+
+	"github.com/qjebbs/go-sqls"
+)
+
+// Relations set the relationship graph, which is used to add the joins to
+// the tables referenced by the selects, conditions, orders, group-bys, etc.
+// but not joined, along the shortest paths from the joined tables. e.g.:
+//
+//	b.Relations(relations).
+//		Select(users.Column("id")).
+//		From(users).
+//		Where2(regions.Column("name"), "=", "eu")
+//	// SELECT u.id FROM users AS u
+//	// LEFT JOIN orgs AS g ON g.id=u.org_id
+//	// LEFT JOIN regions AS r ON r.id=g.region_id
+//	// WHERE r.name=$1
+//
+// The added LEFT JOINs are optional, and the ones matching at most one row
+// are unique, see LeftJoinOptional() and LeftJoinUnique(). It reports an
+// error if there are multiple shortest paths to a table, which should be
+// joined explicitly.
+func (b *QueryBuilder) Relations(r *Relations) *QueryBuilder {
+	b.relations = r
+	return b
+}
+
+// resolveJoins adds the joins to the tables referenced but not joined. It
+// modifies the tables, so it should be called on a copy for every build,
+// see scoped() and derive().
+func (b *QueryBuilder) resolveJoins() error {
+	if b.relations == nil {
+		return nil
+	}
+	if err := b.relations.anyError(); err != nil {
+		return fmt.Errorf("relations: %w", err)
+	}
+	missing := make([]sqls.Table, 0)
+	for _, t := range extractTables(
+		b.selects,
+		b.distinctOn,
+		b.touches,
+		b.conditions,
+		b.groupbys,
+		b.havings,
+		b.windows,
+	) {
+		if b.has(t.Table) {
+			continue
+		}
+		if _, ok := b.relations.edges[t.Table]; !ok {
+			// not declared, reported by the dependency calculation
+			continue
+		}
+		missing = append(missing, t.Table)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sources := make([]sqls.Table, 0, len(b.tables))
+	for _, t := range b.tables {
+		if name := t.AppliedName(); name != "" {
+			sources = append(sources, name)
+		}
+	}
+	paths := b.relations.shortestPaths(sources)
+	for _, t := range missing {
+		path, err := paths.to(t)
+		if err != nil {
+			return err
+		}
+		for _, e := range path {
+			if b.has(e.to.AppliedName()) {
+				continue
+			}
+			if err := b.fromTables.join(e.join, e.to, e.on, e.join == "LEFT JOIN", e.unique); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// relationPaths is the shortest paths from the source tables.
+type relationPaths struct {
+	dist  map[sqls.Table]int           // the distance to the table
+	count map[sqls.Table]int           // the number of the shortest paths to the table
+	prev  map[sqls.Table]sqls.Table    // the previous table on the path
+	via   map[sqls.Table]*relationEdge // the edge reaching the table
+}
+
+// shortestPaths finds the shortest paths from the sources to all the
+// reachable tables, with breadth-first search.
+func (r *Relations) shortestPaths(sources []sqls.Table) *relationPaths {
+	p := &relationPaths{
+		dist:  make(map[sqls.Table]int),
+		count: make(map[sqls.Table]int),
+		prev:  make(map[sqls.Table]sqls.Table),
+		via:   make(map[sqls.Table]*relationEdge),
+	}
+	queue := make([]sqls.Table, 0, len(sources))
+	for _, s := range sources {
+		if _, ok := p.dist[s]; ok {
+			continue
+		}
+		p.dist[s] = 0
+		p.count[s] = 1
+		queue = append(queue, s)
+	}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		for _, e := range r.edges[u] {
+			v := e.to.AppliedName()
+			d, seen := p.dist[v]
+			switch {
+			case !seen:
+				p.dist[v] = p.dist[u] + 1
+				p.count[v] = p.count[u]
+				p.prev[v] = u
+				p.via[v] = e
+				queue = append(queue, v)
+			case d == p.dist[u]+1:
+				p.count[v] += p.count[u]
+			}
+		}
+	}
+	return p
+}
+
+// to returns the edges of the shortest path to the table.
+func (p *relationPaths) to(t sqls.Table) ([]*relationEdge, error) {
+	switch p.count[t] {
+	case 0:
+		// unreachable, reported by the dependency calculation
+		return nil, nil
+	case 1:
+	default:
+		return nil, fmt.Errorf("ambiguous join paths to table '%s' (%d shortest paths), join it explicitly", t, p.count[t])
+	}
+	path := make([]*relationEdge, 0, p.dist[t])
+	for p.dist[t] > 0 {
+		path = append(path, p.via[t])
+		t = p.prev[t]
+	}
+	// from the source to the target
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
@@ -2,6 +2,8 @@ package sqlb_test
 
 import (
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/qjebbs/go-sqls"
@@ -54,7 +56,7 @@ func TestQueryBuilder(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	wantQuery := "With users AS (SELECT * FROM users WHERE type=$1) SELECT DISTINCT f.id, f.name FROM users AS u LEFT JOIN foo AS f ON f.user_id=u.id WHERE u.id=$2 UNION (SELECT f.id, f.name FROM foo AS f WHERE f.id>$3 AND f.id<$4)"
+	wantQuery := "WITH users AS (SELECT * FROM users WHERE type=$1) SELECT DISTINCT f.id, f.name FROM users AS u LEFT JOIN foo AS f ON f.user_id=u.id WHERE u.id=$2 UNION (SELECT f.id, f.name FROM foo AS f WHERE f.id>$3 AND f.id<$4)"
 	wantArgs := []any{"user", 1, 10, 20}
 	if wantQuery != gotQuery {
 		t.Errorf("got:\n%s\nwant:\n%s", gotQuery, wantQuery)
@@ -63,3 +65,1377 @@ func TestQueryBuilder(t *testing.T) {
 		t.Errorf("want:\n%v\ngot:\n%v", wantArgs, gotArgs)
 	}
 }
+
+func TestQueryBuilderHaving(t *testing.T) {
+	var (
+		users  = sqlb.NewTable("users", "u")
+		orders = sqlb.NewTable("orders", "o")
+	)
+	q := sqlb.NewQueryBuilder().
+		BindVar(syntax.Dollar).Distinct().
+		Select(users.Column("id")).
+		From(users).
+		LeftJoinOptional(orders, &sqls.Segment{ // referenced only by HAVING, should be kept
+			Raw: "#c1=#c2",
+			Columns: []*sqls.TableColumn{
+				orders.Column("user_id"),
+				users.Column("id"),
+			},
+		}).
+		Where2(users.Column("active"), "=", true).
+		GroupBy(users.Column("id")).
+		Having2(orders.Expression("SUM(#t1.amount)"), ">", 100).
+		Having(&sqls.Segment{
+			Raw:     "COUNT(#c1) < $1",
+			Columns: orders.Columns("id"),
+			Args:    []any{10},
+		})
+	gotQuery, gotArgs, err := q.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantQuery := "SELECT DISTINCT u.id FROM users AS u LEFT JOIN orders AS o ON o.user_id=u.id WHERE u.active=$1 GROUP BY u.id HAVING SUM(o.amount)>$2 AND COUNT(o.id) < $3"
+	wantArgs := []any{true, 100, 10}
+	if wantQuery != gotQuery {
+		t.Errorf("got:\n%s\nwant:\n%s", gotQuery, wantQuery)
+	}
+	if !reflect.DeepEqual(wantArgs, gotArgs) {
+		t.Errorf("want:\n%v\ngot:\n%v", wantArgs, gotArgs)
+	}
+}
+
+func TestQueryBuilderWindow(t *testing.T) {
+	var (
+		users  = sqlb.NewTable("users", "u")
+		orgs   = sqlb.NewTable("orgs", "g")
+		orders = sqlb.NewTable("orders", "o")
+	)
+	on := func(a, b *sqls.TableColumn) *sqls.Segment {
+		return &sqls.Segment{
+			Raw:     "#c1=#c2",
+			Columns: []*sqls.TableColumn{a, b},
+		}
+	}
+	rowNumber, err := sqlb.NewWindow().
+		PartitionBy(orgs.Column("region")). // referenced only by the window, should be kept
+		OrderBy(users.Column("created_at"), sqlb.Desc).
+		Over(sqlb.Func("ROW_NUMBER()"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := sqlb.NewQueryBuilder().
+		BindVar(syntax.Dollar).Distinct().
+		Select(
+			users.Column("id"),
+			rowNumber,
+			sqlb.OverWindow(sqlb.Func("SUM(#c1)", orders.Column("amount")), "w"),
+		).
+		From(users).
+		LeftJoinOptional(orgs, on(orgs.Column("id"), users.Column("org_id"))).
+		LeftJoinOptional(orders, on(orders.Column("user_id"), users.Column("id"))).
+		Window("w", sqlb.NewWindow().
+			PartitionBy(users.Column("id")).
+			OrderBy(orders.Column("created_at"), sqlb.Asc).
+			Frame("ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW"),
+		)
+	gotQuery, _, err := q.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantQuery := "SELECT DISTINCT u.id, ROW_NUMBER() OVER (PARTITION BY g.region ORDER BY u.created_at DESC), SUM(o.amount) OVER w FROM users AS u LEFT JOIN orgs AS g ON g.id=u.org_id LEFT JOIN orders AS o ON o.user_id=u.id WINDOW w AS (PARTITION BY u.id ORDER BY o.created_at ASC ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW)"
+	if wantQuery != gotQuery {
+		t.Errorf("got:\n%s\nwant:\n%s", gotQuery, wantQuery)
+	}
+
+	// the table referenced only by the named window should be kept too
+	q = sqlb.NewQueryBuilder().
+		BindVar(syntax.Dollar).Distinct().
+		Select(users.Column("id"), sqlb.OverWindow(sqlb.Func("RANK()"), "w")).
+		From(users).
+		LeftJoinOptional(orgs, on(orgs.Column("id"), users.Column("org_id"))).
+		Window("w", sqlb.NewWindow().PartitionBy(orgs.Column("region")))
+	gotQuery, _, err = q.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantQuery = "SELECT DISTINCT u.id, RANK() OVER w FROM users AS u LEFT JOIN orgs AS g ON g.id=u.org_id WINDOW w AS (PARTITION BY g.region)"
+	if wantQuery != gotQuery {
+		t.Errorf("got:\n%s\nwant:\n%s", gotQuery, wantQuery)
+	}
+
+	// invalid orders are reported by both inline and named windows
+	invalid := sqlb.NewWindow().OrderBy(users.Column("id"), sqlb.Order(99))
+	_, err = invalid.Over(sqlb.Func("RANK()"))
+	if err == nil || !strings.Contains(err.Error(), "invalid order: 99") {
+		t.Errorf("want invalid order error, got %v", err)
+	}
+	_, _, err = sqlb.NewQueryBuilder().Select(users.Column("id")).From(users).Window("w", invalid).Build()
+	if err == nil || !strings.Contains(err.Error(), "invalid order: 99") {
+		t.Errorf("want invalid order error, got %v", err)
+	}
+}
+
+func TestQueryBuilderSubqueries(t *testing.T) {
+	var (
+		users  = sqlb.NewTable("users", "u")
+		orders = sqlb.NewTable("orders", "o")
+		active = sqlb.NewTable("", "a")
+		totals = sqlb.NewTable("", "t")
+		counts = sqlb.NewTable("", "c")
+	)
+	activeUsers := sqlb.NewQueryBuilder().
+		Select(users.Column("id")).
+		From(users).
+		Where2(users.Column("active"), "=", true)
+	paidTotals := sqlb.NewQueryBuilder().
+		Select(orders.Column("user_id"), orders.Expression("SUM(#t1.amount) AS amount")).
+		From(orders).
+		Where2(orders.Column("status"), "=", "paid").
+		GroupBy(orders.Column("user_id"))
+	recentOrders := sqlb.NewQueryBuilder().
+		Select(orders.Column("user_id")).
+		From(orders).
+		Where2(orders.Column("created_at"), ">", "2023-01-01")
+	testCases := []struct {
+		name      string
+		where     *sqls.Segment
+		wantQuery string
+		wantArgs  []any
+	}{
+		{
+			name:      "optional derived table trimmed",
+			wantQuery: "SELECT DISTINCT a.id FROM (SELECT u.id FROM users AS u WHERE u.active=$1) AS a INNER JOIN (SELECT o.user_id FROM orders AS o WHERE o.created_at>$2) AS c ON c.user_id=a.id",
+			wantArgs:  []any{true, "2023-01-01"},
+		},
+		{
+			name: "optional derived table kept",
+			where: &sqls.Segment{
+				Raw:     "#c1>$1",
+				Columns: totals.Columns("amount"),
+				Args:    []any{100},
+			},
+			wantQuery: "SELECT DISTINCT a.id FROM (SELECT u.id FROM users AS u WHERE u.active=$1) AS a LEFT JOIN (SELECT o.user_id, SUM(o.amount) AS amount FROM orders AS o WHERE o.status=$2 GROUP BY o.user_id) AS t ON t.user_id=a.id INNER JOIN (SELECT o.user_id FROM orders AS o WHERE o.created_at>$3) AS c ON c.user_id=a.id WHERE t.amount>$4",
+			wantArgs:  []any{true, "paid", "2023-01-01", 100},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotQuery, gotArgs, err := newQuery(sqlb.DialectPostgreSQL, active.Column("id")).
+				Distinct().
+				FromQuery(activeUsers, "a").
+				LeftJoinOptionalQuery(paidTotals, "t", eq(totals.Column("user_id"), active.Column("id"))).
+				InnerJoinQuery(recentOrders, "c", eq(counts.Column("user_id"), active.Column("id"))).
+				Where(tc.where).
+				Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.wantQuery != gotQuery {
+				t.Errorf("got:\n%s\nwant:\n%s", gotQuery, tc.wantQuery)
+			}
+			if !reflect.DeepEqual(tc.wantArgs, gotArgs) {
+				t.Errorf("want:\n%v\ngot:\n%v", tc.wantArgs, gotArgs)
+			}
+		})
+	}
+}
+
+func TestQueryBuilderLateral(t *testing.T) {
+	var (
+		users  = sqlb.NewTable("users", "u")
+		groups = sqlb.NewTable("groups", "g")
+		orders = sqlb.NewTable("orders", "o")
+		latest = sqlb.NewTable("", "l")
+	)
+	latestOrders := sqlb.NewQueryBuilder().
+		Select(orders.Column("amount")).
+		From(orders).
+		Where(&sqls.Segment{
+			Raw:     "#c1=#c2 AND #c3>=#c4",
+			Columns: []*sqls.TableColumn{orders.Column("user_id"), users.Column("id"), orders.Column("amount"), groups.Column("min_amount")},
+		}).
+		OrderBy(orders.Column("created_at"), sqlb.Desc).
+		Limit(3)
+	testCases := []struct {
+		name      string
+		dialect   sqlb.Dialect
+		wantQuery string
+		wantErr   string
+	}{
+		{
+			name:      "postgres",
+			dialect:   sqlb.DialectPostgreSQL,
+			wantQuery: "SELECT DISTINCT u.id, l.amount FROM users AS u LEFT JOIN groups AS g ON g.id=u.group_id LEFT JOIN LATERAL (SELECT o.amount, o.created_at AS _order_1 FROM orders AS o WHERE o.user_id=u.id AND o.amount>=g.min_amount ORDER BY _order_1 DESC LIMIT 3) AS l ON TRUE",
+		},
+		{
+			name:      "sqlserver",
+			dialect:   sqlb.DialectSQLServer,
+			wantQuery: "SELECT DISTINCT u.id, l.amount FROM users AS u LEFT JOIN groups AS g ON g.id=u.group_id OUTER APPLY (SELECT o.amount, o.created_at AS _order_1 FROM orders AS o WHERE o.user_id=u.id AND o.amount>=g.min_amount ORDER BY _order_1 DESC LIMIT 3) AS l",
+		},
+		{
+			name:    "sqlite",
+			dialect: sqlb.DialectSQLite,
+			wantErr: "lateral join is not supported by SQLite",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotQuery, _, err := newQuery(tc.dialect, users.Column("id"), latest.Column("amount")).
+				Distinct().
+				From(users).
+				// referenced only by the lateral subquery, should be kept
+				LeftJoinOptional(groups, eq(groups.Column("id"), users.Column("group_id"))).
+				LeftJoinLateral(latestOrders, "l", nil).
+				Build()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("want error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.wantQuery != gotQuery {
+				t.Errorf("got:\n%s\nwant:\n%s", gotQuery, tc.wantQuery)
+			}
+		})
+	}
+
+	// inner lateral join with condition
+	gotQuery, _, err := newQuery(sqlb.DialectPostgreSQL, users.Column("id")).
+		From(users).
+		InnerJoinLateral(
+			&sqls.Segment{
+				Raw:     "SELECT COUNT(*) AS n FROM orders WHERE user_id=#c1",
+				Columns: users.Columns("id"),
+			},
+			"c",
+			&sqls.Segment{Raw: "c.n > 0"},
+		).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantQuery := "SELECT u.id FROM users AS u INNER JOIN LATERAL (SELECT COUNT(*) AS n FROM orders WHERE user_id=u.id) AS c ON c.n > 0"
+	if wantQuery != gotQuery {
+		t.Errorf("got:\n%s\nwant:\n%s", gotQuery, wantQuery)
+	}
+
+	// the subquery is not bound to the outer query after building
+	sub := sqlb.NewQueryBuilder().
+		Select(orders.Column("amount")).
+		From(orders).
+		Where(eq(orders.Column("user_id"), users.Column("id")))
+	q := sqlb.NewQueryBuilder().
+		Select(users.Column("id"), latest.Column("amount")).
+		From(users).
+		LeftJoinLateral(sub, "l", nil)
+	if _, _, err := q.Build(); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = sub.Build()
+	if err == nil || !strings.Contains(err.Error(), "table not found: 'u'") {
+		t.Errorf("want table not found error for the standalone subquery, got %v", err)
+	}
+}
+
+func TestQueryBuilderCTEs(t *testing.T) {
+	var (
+		tree    = sqlb.NewTable("tree", "t")
+		nodes   = sqlb.NewTable("nodes", "n")
+		recent  = sqlb.NewTable("recent", "r")
+		orders  = sqlb.NewTable("orders", "o")
+		unused  = sqlb.NewTable("unused", "x")
+		topUser = sqlb.NewTable("top_users", "tu")
+	)
+	testCases := []struct {
+		name      string
+		query     *sqlb.QueryBuilder
+		wantQuery string
+		wantArgs  []any
+	}{
+		{
+			name: "recursive",
+			query: sqlb.NewQueryBuilder().
+				BindVar(syntax.Dollar).
+				WithRecursive(tree.Name, []string{"id", "parent_id"},
+					&sqls.Segment{
+						Raw:  "SELECT id, parent_id FROM nodes WHERE id = $1",
+						Args: []any{1},
+					},
+					sqlb.NewQueryBuilder().
+						Select(nodes.Columns("id", "parent_id")...).
+						From(nodes).
+						InnerJoin(tree, &sqls.Segment{
+							Raw:     "#c1=#c2",
+							Columns: []*sqls.TableColumn{nodes.Column("parent_id"), tree.Column("id")},
+						}),
+				).
+				Select(tree.Column("id")).
+				From(tree).
+				Where2(tree.Column("id"), "<>", 1),
+			wantQuery: "WITH RECURSIVE tree (id, parent_id) AS (SELECT id, parent_id FROM nodes WHERE id = $1 UNION ALL SELECT n.id, n.parent_id FROM nodes AS n INNER JOIN tree AS t ON n.parent_id=t.id) SELECT t.id FROM tree AS t WHERE t.id<>$2",
+			wantArgs:  []any{1, 1},
+		},
+		{
+			name: "transitive and pruned",
+			query: sqlb.NewQueryBuilder().
+				BindVar(syntax.Dollar).
+				With(recent.Name, &sqls.Segment{
+					Raw:  "SELECT * FROM orders WHERE created_at > $1",
+					Args: []any{"2023-01-01"},
+				}, sqlb.CTEMaterialized()).
+				With(unused.Name, &sqls.Segment{Raw: "SELECT 1"}).
+				With(topUser.Name, sqlb.NewQueryBuilder().
+					Select(recent.Column("user_id")).
+					From(recent).
+					GroupBy(recent.Column("user_id")).
+					Having2(recent.Expression("SUM(#t1.amount)"), ">", 100),
+					sqlb.CTEColumns("id"), sqlb.CTENotMaterialized(),
+				).
+				Select(orders.Column("id")).
+				From(orders).
+				InnerJoin(topUser, &sqls.Segment{
+					Raw:     "#c1=#c2",
+					Columns: []*sqls.TableColumn{topUser.Column("id"), orders.Column("user_id")},
+				}),
+			wantQuery: "WITH recent AS MATERIALIZED (SELECT * FROM orders WHERE created_at > $1), top_users (id) AS NOT MATERIALIZED (SELECT r.user_id FROM recent AS r GROUP BY r.user_id HAVING SUM(r.amount)>$2) SELECT o.id FROM orders AS o INNER JOIN top_users AS tu ON tu.id=o.user_id",
+			wantArgs:  []any{"2023-01-01", 100},
+		},
+		{
+			name: "declared before dependencies",
+			query: sqlb.NewQueryBuilder().
+				BindVar(syntax.Dollar).
+				With(topUser.Name, sqlb.NewQueryBuilder().
+					Select(recent.Column("user_id")).
+					From(recent),
+				).
+				With(recent.Name, &sqls.Segment{
+					Raw:  "SELECT * FROM orders WHERE created_at > $1",
+					Args: []any{"2023-01-01"},
+				}).
+				Select(topUser.Column("user_id")).
+				From(topUser),
+			wantQuery: "WITH recent AS (SELECT * FROM orders WHERE created_at > $1), top_users AS (SELECT r.user_id FROM recent AS r) SELECT tu.user_id FROM top_users AS tu",
+			wantArgs:  []any{"2023-01-01"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotQuery, gotArgs, err := tc.query.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.wantQuery != gotQuery {
+				t.Errorf("got:\n%s\nwant:\n%s", gotQuery, tc.wantQuery)
+			}
+			if !reflect.DeepEqual(tc.wantArgs, gotArgs) {
+				t.Errorf("want:\n%v\ngot:\n%v", tc.wantArgs, gotArgs)
+			}
+		})
+	}
+}
+
+func TestQueryBuilderLocking(t *testing.T) {
+	var (
+		jobs    = sqlb.NewTable("jobs", "j")
+		workers = sqlb.NewTable("workers", "w")
+	)
+	id := jobs.Column("id")
+	pg, mysql := sqlb.DialectPostgreSQL, sqlb.DialectMySQL
+	testCases := []struct {
+		name    string
+		query   *sqlb.QueryBuilder
+		want    string
+		wantErr string
+	}{
+		{
+			name: "for update skip locked",
+			query: newQuery(pg, id).From(jobs).
+				Where2(jobs.Column("status"), "=", "pending").
+				Limit(10).Offset(20).
+				ForUpdate().Of(jobs).SkipLocked(),
+			want: "SELECT j.id FROM jobs AS j WHERE j.status=$1 LIMIT 10 OFFSET 20 FOR UPDATE OF j SKIP LOCKED",
+		},
+		{
+			name: "multiple clauses",
+			query: newQuery(pg, id).From(jobs).
+				LeftJoinOptional(workers, eq(workers.Column("id"), jobs.Column("worker_id"))).
+				ForNoKeyUpdate().Of(jobs).ForShare().Of(workers).NoWait(),
+			want: "SELECT j.id FROM jobs AS j LEFT JOIN workers AS w ON w.id=j.worker_id FOR NO KEY UPDATE OF j FOR SHARE OF w NOWAIT",
+		},
+		{
+			name:  "mysql",
+			query: newQuery(mysql, id).From(jobs).Limit(10).Offset(20).ForShare().SkipLocked(),
+			want:  "SELECT j.id FROM jobs AS j LIMIT 20, 10 FOR SHARE SKIP LOCKED",
+		},
+		{
+			name:    "no key update mysql",
+			query:   newQuery(mysql, id).From(jobs).ForNoKeyUpdate(),
+			wantErr: "FOR NO KEY UPDATE is not supported by MySQL",
+		},
+		{
+			name:    "sqlite",
+			query:   newQuery(sqlb.DialectSQLite, id).From(jobs).ForUpdate(),
+			wantErr: "row locking is not supported by SQLite",
+		},
+		{
+			name:    "distinct",
+			query:   newQuery(pg, id).From(jobs).Distinct().ForUpdate(),
+			wantErr: "locking is not allowed with DISTINCT",
+		},
+		{
+			name:    "group by",
+			query:   newQuery(pg, id).From(jobs).GroupBy(id).ForUpdate(),
+			wantErr: "locking is not allowed with GROUP BY",
+		},
+		{
+			name:    "union",
+			query:   newQuery(pg, id).From(jobs).Union(newQuery(pg, id).From(jobs)).ForUpdate(),
+			wantErr: "locking is not allowed with UNION",
+		},
+		{
+			name:    "modifier without clause",
+			query:   newQuery(pg, id).From(jobs).SkipLocked(),
+			wantErr: "SkipLocked: no locking clause",
+		},
+		{
+			name:    "unknown table",
+			query:   newQuery(pg, id).From(jobs).ForUpdate().Of(sqlb.NewTable("foo", "f")),
+			wantErr: "table not found: 'f'",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, _, err := tc.query.Build()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("want error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestQueryBuilderDistinctOn(t *testing.T) {
+	var (
+		users  = sqlb.NewTable("users", "u")
+		orders = sqlb.NewTable("orders", "o")
+		items  = sqlb.NewTable("items", "i")
+	)
+	selected := orders.Columns("user_id", "id")
+	pg := sqlb.DialectPostgreSQL
+	testCases := []struct {
+		name    string
+		query   *sqlb.QueryBuilder
+		want    string
+		wantErr string
+	}{
+		{
+			name: "latest row per key",
+			query: newQuery(pg, selected...).From(orders).
+				LeftJoinOptional(users, eq(users.Column("id"), orders.Column("user_id"))).
+				LeftJoinOptional(items, eq(items.Column("order_id"), orders.Column("id"))). // not referenced, should be trimmed
+				DistinctOn(orders.Column("user_id")).
+				OrderBy(orders.Column("user_id"), sqlb.Asc).
+				OrderBy(users.Column("created_at"), sqlb.Desc), // referenced only by ORDER BY, should be kept
+			want: "SELECT DISTINCT ON (o.user_id) o.user_id, o.id FROM orders AS o LEFT JOIN users AS u ON u.id=o.user_id ORDER BY o.user_id ASC, u.created_at DESC",
+		},
+		{
+			name: "leading orders in any order",
+			query: newQuery(pg, selected...).From(orders).
+				DistinctOn(orders.Column("user_id"), orders.Column("status")).
+				OrderBy(orders.Column("status"), sqlb.Asc).
+				OrderBy(orders.Column("user_id"), sqlb.Asc),
+			want: "SELECT DISTINCT ON (o.user_id, o.status) o.user_id, o.id FROM orders AS o ORDER BY o.status ASC, o.user_id ASC",
+		},
+		{
+			name:  "without order",
+			query: newQuery(pg, selected...).From(orders).DistinctOn(orders.Column("user_id")),
+			want:  "SELECT DISTINCT ON (o.user_id) o.user_id, o.id FROM orders AS o",
+		},
+		{
+			name: "order mismatch",
+			query: newQuery(pg, selected...).From(orders).
+				DistinctOn(orders.Column("user_id")).
+				OrderBy(orders.Column("created_at"), sqlb.Desc),
+			wantErr: "DISTINCT ON expressions must match the leading ORDER BY expressions",
+		},
+		{
+			name: "duplicate leading orders",
+			query: newQuery(pg, selected...).From(orders).
+				DistinctOn(orders.Column("user_id"), orders.Column("status")).
+				OrderBy(orders.Column("user_id"), sqlb.Asc).
+				OrderBy(orders.Column("user_id"), sqlb.Desc),
+			wantErr: "DISTINCT ON expressions must match the leading ORDER BY expressions",
+		},
+		{
+			name: "same built column",
+			query: newQuery(pg, selected...).From(orders).
+				DistinctOn(orders.Column("user_id")).
+				OrderBy(sqlb.Func("#c1", orders.Column("user_id")), sqlb.Asc),
+			want: "SELECT DISTINCT ON (o.user_id) o.user_id, o.id FROM orders AS o ORDER BY o.user_id ASC",
+		},
+		{
+			name:    "mysql",
+			query:   newQuery(sqlb.DialectMySQL, selected...).From(orders).DistinctOn(orders.Column("user_id")),
+			wantErr: "DISTINCT ON is not supported by MySQL",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, _, err := tc.query.Build()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("want error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestQueryBuilderPagination(t *testing.T) {
+	var (
+		users  = sqlb.NewTable("users", "u")
+		orders = sqlb.NewTable("orders", "o")
+	)
+	id := users.Column("id")
+	testCases := []struct {
+		name     string
+		query    *sqlb.QueryBuilder
+		want     string
+		wantArgs []any
+		wantErr  string
+	}{
+		{
+			name:     "postgres",
+			query:    newQuery(sqlb.DialectPostgreSQL, id).From(users).Where2(users.Column("active"), "=", true).Limit(10).Offset(20),
+			want:     "SELECT u.id FROM users AS u WHERE u.active=$1 LIMIT 10 OFFSET 20",
+			wantArgs: []any{true},
+		},
+		{
+			name:     "postgres bindvars",
+			query:    newQuery(sqlb.DialectPostgreSQL, id).From(users).Where2(users.Column("active"), "=", true).Limit(10).Offset(20).BindPagination(),
+			want:     "SELECT u.id FROM users AS u WHERE u.active=$1 LIMIT $2 OFFSET $3",
+			wantArgs: []any{true, int64(10), int64(20)},
+		},
+		{
+			name:     "sqlite offset only",
+			query:    newQuery(sqlb.DialectSQLite, id).From(users).Where2(users.Column("active"), "=", true).Offset(20),
+			want:     "SELECT u.id FROM users AS u WHERE u.active=$1 LIMIT -1 OFFSET 20",
+			wantArgs: []any{true},
+		},
+		{
+			name:     "mysql",
+			query:    newQuery(sqlb.DialectMySQL, id).From(users).Where2(users.Column("active"), "=", true).Limit(10).Offset(20).BindPagination(),
+			want:     "SELECT u.id FROM users AS u WHERE u.active=$1 LIMIT $2, $3",
+			wantArgs: []any{true, int64(20), int64(10)},
+		},
+		{
+			name:     "mysql offset only",
+			query:    newQuery(sqlb.DialectMySQL, id).From(users).Where2(users.Column("active"), "=", true).Offset(20),
+			want:     "SELECT u.id FROM users AS u WHERE u.active=$1 LIMIT 20, 18446744073709551615",
+			wantArgs: []any{true},
+		},
+		{
+			name:     "sql server top",
+			query:    newQuery(sqlb.DialectSQLServer, id).From(users).Where2(users.Column("active"), "=", true).Distinct().Limit(10).BindPagination(),
+			want:     "SELECT DISTINCT TOP ($1) u.id FROM users AS u WHERE u.active=$2",
+			wantArgs: []any{int64(10), true},
+		},
+		{
+			name:     "sql server offset fetch",
+			query:    newQuery(sqlb.DialectSQLServer, id).From(users).Where2(users.Column("active"), "=", true).OrderBy(users.Column("id"), sqlb.Asc).Limit(10).Offset(20),
+			want:     "SELECT u.id, u.id AS _order_1 FROM users AS u WHERE u.active=$1 ORDER BY _order_1 ASC OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY",
+			wantArgs: []any{true},
+		},
+		{
+			name:    "sql server offset without order",
+			query:   newQuery(sqlb.DialectSQLServer, id).From(users).Where2(users.Column("active"), "=", true).Offset(20),
+			wantErr: "OFFSET FETCH requires ORDER BY in SQL Server",
+		},
+		{
+			name:     "oracle",
+			query:    newQuery(sqlb.DialectOracle, id).From(users).Where2(users.Column("active"), "=", true).Limit(10),
+			want:     "SELECT u.id FROM users u WHERE u.active=$1 FETCH FIRST 10 ROWS ONLY",
+			wantArgs: []any{true},
+		},
+		{
+			name: "oracle aliases",
+			query: newQuery(sqlb.DialectPostgreSQL, id, sqlb.NewTable("", "c").Column("n")).
+				From(users).
+				InnerJoinQuery(
+					sqlb.NewQueryBuilder().
+						Dialect(sqlb.DialectOracle).
+						Select(orders.Column("user_id"), orders.Expression("COUNT(*) AS n")).
+						From(orders).
+						GroupBy(orders.Column("user_id")),
+					"c",
+					eq(sqlb.NewTable("", "c").Column("user_id"), id),
+				).
+				OrderBy(users.Column("id"), sqlb.Asc).
+				Offset(5).
+				Limit(10).
+				Dialect(sqlb.DialectOracle), // set after the tables
+			want:     "SELECT u.id, c.n, u.id AS _order_1 FROM users u INNER JOIN (SELECT o.user_id, COUNT(*) AS n FROM orders o GROUP BY o.user_id) c ON c.user_id=u.id ORDER BY _order_1 ASC OFFSET 5 ROWS FETCH NEXT 10 ROWS ONLY",
+			wantArgs: []any{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, gotArgs, err := tc.query.Build()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("want error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+			if !reflect.DeepEqual(tc.wantArgs, gotArgs) {
+				t.Errorf("want:\n%v\ngot:\n%v", tc.wantArgs, gotArgs)
+			}
+		})
+	}
+}
+
+func TestQueryBuilderKeyset(t *testing.T) {
+	var users = sqlb.NewTable("users", "u")
+	id := users.Column("id")
+	pg := sqlb.DialectPostgreSQL
+	testCases := []struct {
+		name     string
+		query    *sqlb.QueryBuilder
+		want     string
+		wantArgs []any
+		wantErr  string
+	}{
+		{
+			name: "row value",
+			query: newQuery(pg, id).From(users).Where2(users.Column("active"), "=", true).Limit(20).
+				OrderBy(users.Column("created_at"), sqlb.Desc).
+				OrderBy(users.Column("id"), sqlb.Desc).
+				After("2023-01-01", 100),
+			want:     "SELECT u.id, u.created_at AS _order_1, u.id AS _order_2 FROM users AS u WHERE u.active=$1 AND (u.created_at, u.id) < ($2, $3) ORDER BY _order_1 DESC, _order_2 DESC LIMIT 20",
+			wantArgs: []any{true, "2023-01-01", 100},
+		},
+		{
+			name: "before reverses the order",
+			query: newQuery(pg, id).From(users).Where2(users.Column("active"), "=", true).Limit(20).
+				OrderBy(users.Column("created_at"), sqlb.Desc).
+				OrderBy(users.Column("id"), sqlb.Desc).
+				Before("2023-01-01", 100),
+			want:     "SELECT u.id, u.created_at AS _order_1, u.id AS _order_2 FROM users AS u WHERE u.active=$1 AND (u.created_at, u.id) > ($2, $3) ORDER BY _order_1 ASC, _order_2 ASC LIMIT 20",
+			wantArgs: []any{true, "2023-01-01", 100},
+		},
+		{
+			name: "mixed directions",
+			query: newQuery(pg, id).From(users).Where2(users.Column("active"), "=", true).Limit(20).
+				OrderBy(users.Column("created_at"), sqlb.Desc).
+				OrderBy(users.Column("id"), sqlb.Asc).
+				After("2023-01-01", 100),
+			want:     "SELECT u.id, u.created_at AS _order_1, u.id AS _order_2 FROM users AS u WHERE u.active=$1 AND (u.created_at < $2 OR (u.created_at = $2 AND u.id > $3)) ORDER BY _order_1 DESC, _order_2 ASC LIMIT 20",
+			wantArgs: []any{true, "2023-01-01", 100},
+		},
+		{
+			name: "nulls last",
+			query: newQuery(pg, id).From(users).Where2(users.Column("active"), "=", true).Limit(20).
+				OrderBy(users.Column("score"), sqlb.AscNullsLast).
+				OrderBy(users.Column("id"), sqlb.Asc).
+				After(10, 100),
+			want:     "SELECT u.id, u.score AS _order_1, u.id AS _order_2 FROM users AS u WHERE u.active=$1 AND ((u.score > $2 OR u.score IS NULL) OR (u.score = $2 AND u.id > $3)) ORDER BY _order_1 ASC NULLS LAST, _order_2 ASC LIMIT 20",
+			wantArgs: []any{true, 10, 100},
+		},
+		{
+			name: "null value",
+			query: newQuery(pg, id).From(users).Where2(users.Column("active"), "=", true).Limit(20).
+				OrderBy(users.Column("score"), sqlb.AscNullsLast).
+				OrderBy(users.Column("id"), sqlb.Asc).
+				After(nil, 100),
+			want:     "SELECT u.id, u.score AS _order_1, u.id AS _order_2 FROM users AS u WHERE u.active=$1 AND u.score IS NULL AND u.id > $2 ORDER BY _order_1 ASC NULLS LAST, _order_2 ASC LIMIT 20",
+			wantArgs: []any{true, 100},
+		},
+		{
+			name: "null value before",
+			query: newQuery(pg, id).From(users).Where2(users.Column("active"), "=", true).Limit(20).
+				OrderBy(users.Column("score"), sqlb.AscNullsLast).
+				OrderBy(users.Column("id"), sqlb.Asc).
+				Before(nil, 100),
+			want:     "SELECT u.id, u.score AS _order_1, u.id AS _order_2 FROM users AS u WHERE u.active=$1 AND (u.score IS NOT NULL OR (u.score IS NULL AND u.id < $2)) ORDER BY _order_1 DESC NULLS FIRST, _order_2 DESC LIMIT 20",
+			wantArgs: []any{true, 100},
+		},
+		{
+			name: "sql server",
+			query: newQuery(sqlb.DialectSQLServer, id).From(users).Where2(users.Column("active"), "=", true).Limit(20).
+				OrderBy(users.Column("created_at"), sqlb.Desc).
+				OrderBy(users.Column("id"), sqlb.Desc).
+				After("2023-01-01", 100),
+			want:     "SELECT TOP (20) u.id, u.created_at AS _order_1, u.id AS _order_2 FROM users AS u WHERE u.active=$1 AND (u.created_at < $2 OR (u.created_at = $2 AND u.id < $3)) ORDER BY _order_1 DESC, _order_2 DESC",
+			wantArgs: []any{true, "2023-01-01", 100},
+		},
+		{
+			name:    "null without nulls order",
+			query:   newQuery(pg, id).From(users).Where2(users.Column("active"), "=", true).Limit(20).OrderBy(users.Column("score"), sqlb.Asc).After(nil),
+			wantErr: "keyset cursor value 1 is NULL",
+		},
+		{
+			name:    "values mismatch",
+			query:   newQuery(pg, id).From(users).Where2(users.Column("active"), "=", true).Limit(20).OrderBy(users.Column("id"), sqlb.Asc).After(1, 2),
+			wantErr: "keyset cursor has 2 values, want 1",
+		},
+		{
+			name:    "no order",
+			query:   newQuery(pg, id).From(users).Where2(users.Column("active"), "=", true).Limit(20).After(1),
+			wantErr: "keyset pagination requires ORDER BY",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, gotArgs, err := tc.query.Build()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("want error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+			if !reflect.DeepEqual(tc.wantArgs, gotArgs) {
+				t.Errorf("want:\n%v\ngot:\n%v", tc.wantArgs, gotArgs)
+			}
+		})
+	}
+}
+
+func TestQueryBuilderCountBuilder(t *testing.T) {
+	var (
+		users  = sqlb.NewTable("users", "u")
+		orders = sqlb.NewTable("orders", "o")
+		active = sqlb.NewTable("active_users", "")
+	)
+	selected := users.Columns("id", "name")
+	ordersOn := eq(orders.Column("user_id"), users.Column("id"))
+	pg := sqlb.DialectPostgreSQL
+	testCases := []struct {
+		name     string
+		query    *sqlb.QueryBuilder
+		pk       *sqls.TableColumn
+		want     string
+		wantArgs []any
+	}{
+		{
+			name: "plain",
+			query: newQuery(pg, selected...).From(users).
+				LeftJoinOptional(orders, ordersOn).
+				Where2(users.Column("active"), "=", true).
+				OrderBy(orders.Column("created_at"), sqlb.Desc).
+				Limit(10).Offset(20),
+			want:     "SELECT COUNT(*) FROM users AS u LEFT JOIN orders AS o ON o.user_id=u.id WHERE u.active=$1",
+			wantArgs: []any{true},
+		},
+		{
+			name: "distinct with pk",
+			query: newQuery(pg, selected...).From(users).
+				LeftJoinOptional(orders, ordersOn).
+				Where2(users.Column("active"), "=", true).
+				OrderBy(orders.Column("created_at"), sqlb.Desc).
+				Limit(10).Offset(20).
+				Distinct(),
+			pk:       users.Column("id"),
+			want:     "SELECT COUNT(DISTINCT u.id) FROM users AS u WHERE u.active=$1",
+			wantArgs: []any{true},
+		},
+		{
+			name: "distinct without pk",
+			query: newQuery(pg, selected...).From(users).
+				LeftJoinOptional(orders, ordersOn).
+				Where2(users.Column("active"), "=", true).
+				OrderBy(orders.Column("created_at"), sqlb.Desc).
+				Limit(10).Offset(20).
+				Distinct(),
+			want:     "SELECT COUNT(*) FROM (SELECT DISTINCT u.id, u.name FROM users AS u WHERE u.active=$1) AS list",
+			wantArgs: []any{true},
+		},
+		{
+			name: "distinct on",
+			query: newQuery(pg, orders.Columns("user_id", "id")...).From(orders).
+				DistinctOn(orders.Column("user_id")).
+				Where2(orders.Column("status"), "=", "paid").
+				OrderBy(orders.Column("user_id"), sqlb.Asc).
+				OrderBy(orders.Column("created_at"), sqlb.Desc),
+			want:     "SELECT COUNT(*) FROM (SELECT DISTINCT o.user_id FROM orders AS o WHERE o.status=$1) AS list",
+			wantArgs: []any{"paid"},
+		},
+		{
+			name: "group by with CTE",
+			query: newQuery(pg, selected...).From(users).
+				LeftJoinOptional(orders, ordersOn).
+				Where2(users.Column("active"), "=", true).
+				With(active.Name, &sqls.Segment{Raw: "SELECT * FROM users WHERE active"}).
+				InnerJoin(active, eq(active.Column("id"), users.Column("id"))).
+				GroupBy(users.Column("id")).
+				Having2(orders.Expression("COUNT(#t1.id)"), ">", 1),
+			want:     "WITH active_users AS (SELECT * FROM users WHERE active) SELECT COUNT(*) FROM (SELECT 1 FROM users AS u LEFT JOIN orders AS o ON o.user_id=u.id INNER JOIN active_users ON active_users.id=u.id WHERE u.active=$1 GROUP BY u.id HAVING COUNT(o.id)>$2) AS list",
+			wantArgs: []any{true, 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			before, beforeArgs, err := tc.query.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, gotArgs, err := tc.query.CountBuilder(tc.pk).Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+			if !reflect.DeepEqual(tc.wantArgs, gotArgs) {
+				t.Errorf("want:\n%v\ngot:\n%v", tc.wantArgs, gotArgs)
+			}
+			// the base builder is not changed
+			after, afterArgs, err := tc.query.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after != before || !reflect.DeepEqual(afterArgs, beforeArgs) {
+				t.Errorf("base builder changed:\n%s\nwant:\n%s", after, before)
+			}
+		})
+	}
+
+	// the derived builder is not changed by the later modification of the
+	// base builder, and vice versa
+	base := newQuery(pg, selected...).From(users).
+		LeftJoinOptional(orders, ordersOn).
+		Where2(users.Column("active"), "=", true).
+		OrderBy(orders.Column("created_at"), sqlb.Desc).
+		Limit(10).Offset(20)
+	count := base.CountBuilder()
+	base.Where2(users.Column("name"), "=", "alice")
+	count.Where2(orders.Column("amount"), ">", 100)
+	got, gotArgs, err := count.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SELECT COUNT(*) FROM users AS u LEFT JOIN orders AS o ON o.user_id=u.id WHERE u.active=$1 AND o.amount>$2"
+	if got != want || !reflect.DeepEqual(gotArgs, []any{true, 100}) {
+		t.Errorf("got:\n%s %v\nwant:\n%s", got, gotArgs, want)
+	}
+	got, _, err = base.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "SELECT u.id, u.name, o.created_at AS _order_1 FROM users AS u LEFT JOIN orders AS o ON o.user_id=u.id WHERE u.active=$1 AND u.name=$2 ORDER BY _order_1 DESC LIMIT 10 OFFSET 20"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	_, _, err = newQuery(pg, selected...).From(users).Distinct().CountBuilder(users.Column("id"), users.Column("name")).Build()
+	if err == nil || !strings.Contains(err.Error(), "at most one pk") {
+		t.Fatalf("want error %q, got %v", "at most one pk", err)
+	}
+}
+
+func TestQueryBuilderCondGroups(t *testing.T) {
+	var (
+		users  = sqlb.NewTable("users", "u")
+		orders = sqlb.NewTable("orders", "o")
+	)
+	q := sqlb.NewQueryBuilder().
+		BindVar(syntax.Dollar).Distinct().
+		Select(users.Column("id")).
+		From(users).
+		LeftJoinOptional(orders, &sqls.Segment{ // referenced only in nested group, should be kept
+			Raw:     "#c1=#c2",
+			Columns: []*sqls.TableColumn{orders.Column("user_id"), users.Column("id")},
+		}).
+		Where2(users.Column("active"), "=", true).
+		WhereAny(func(g *sqlb.CondGroup) {
+			g.Where2(users.Column("role"), "=", "admin").
+				WhereAll(func(g *sqlb.CondGroup) {
+					g.WhereIn(users.Column("role"), []string{"editor", "author"}).
+						WhereAny(func(g *sqlb.CondGroup) {
+							g.Where2(orders.Column("amount"), ">", 100)
+						})
+				}).
+				WhereAll(func(g *sqlb.CondGroup) {}) // empty, should be dropped
+		}).
+		WhereNot(func(g *sqlb.CondGroup) {
+			g.Where2(users.Column("banned"), "=", true).
+				WhereNotIn(users.Column("id"), []int{1, 2})
+		}).
+		WhereAny(func(g *sqlb.CondGroup) {}) // empty, should be dropped
+	gotQuery, gotArgs, err := q.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantQuery := "SELECT DISTINCT u.id FROM users AS u LEFT JOIN orders AS o ON o.user_id=u.id WHERE u.active=$1 AND (u.role=$2 OR (u.role IN ($3, $4) AND o.amount>$5)) AND NOT (u.banned=$6 AND u.id NOT IN ($7, $8))"
+	wantArgs := []any{true, "admin", "editor", "author", 100, true, 1, 2}
+	if wantQuery != gotQuery {
+		t.Errorf("got:\n%s\nwant:\n%s", gotQuery, wantQuery)
+	}
+	if !reflect.DeepEqual(wantArgs, gotArgs) {
+		t.Errorf("want:\n%v\ngot:\n%v", wantArgs, gotArgs)
+	}
+}
+
+func TestQueryBuilderPredicates(t *testing.T) {
+	var (
+		users  = sqlb.NewTable("users", "u")
+		teams  = sqlb.NewTable("teams", "t")
+		orders = sqlb.NewTable("orders", "o")
+		bans   = sqlb.NewTable("bans", "b")
+	)
+	q := sqlb.NewQueryBuilder().
+		BindVar(syntax.Dollar).Distinct().
+		Select(users.Column("id")).
+		From(users).
+		LeftJoinOptional(teams, &sqls.Segment{ // referenced only by the subquery, should be kept
+			Raw:     "#c1=#c2",
+			Columns: []*sqls.TableColumn{teams.Column("id"), users.Column("team_id")},
+		}).
+		WhereBetween(users.Column("age"), 18, 30).
+		WhereNull(users.Column("deleted_at")).
+		WhereNotNull(users.Column("email")).
+		Where2(users.Column("name"), "not like", "a%").
+		WhereLike(users.Column("nickname"), "50%_off!", sqlb.LikeContains).
+		WhereExists(
+			sqlb.NewQueryBuilder().
+				Select(sqlb.Func("1")).
+				From(orders).
+				Where(&sqls.Segment{
+					Raw:     "#c1=#c2 AND #c3=#c4",
+					Columns: []*sqls.TableColumn{orders.Column("user_id"), users.Column("id"), orders.Column("region"), teams.Column("region")},
+				}).
+				Where2(orders.Column("amount"), ">", 100),
+		).
+		WhereAny(func(g *sqlb.CondGroup) {
+			g.WhereNotExists(
+				sqlb.NewQueryBuilder().
+					Select(sqlb.Func("1")).
+					From(bans).
+					Where(&sqls.Segment{
+						Raw:     "#c1=#c2",
+						Columns: []*sqls.TableColumn{bans.Column("user_id"), users.Column("id")},
+					}),
+			).WhereLike(users.Column("role"), "admin", sqlb.LikePrefix)
+		}).
+		WhereInQuery(
+			users.Column("id"),
+			sqlb.NewQueryBuilder().Select(orders.Column("user_id")).From(orders).Where2(orders.Column("status"), "=", "paid"),
+		)
+	gotQuery, gotArgs, err := q.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantQuery := "SELECT DISTINCT u.id FROM users AS u LEFT JOIN teams AS t ON t.id=u.team_id WHERE u.age BETWEEN $1 AND $2 AND u.deleted_at IS NULL AND u.email IS NOT NULL AND u.name NOT LIKE $3 AND u.nickname LIKE $4 ESCAPE '!' AND EXISTS (SELECT 1 FROM orders AS o WHERE o.user_id=u.id AND o.region=t.region AND o.amount>$5) AND (NOT EXISTS (SELECT 1 FROM bans AS b WHERE b.user_id=u.id) OR u.role LIKE $6 ESCAPE '!') AND u.id IN (SELECT o.user_id FROM orders AS o WHERE o.status=$7)"
+	wantArgs := []any{18, 30, "a%", "%50!%!_off!!%", 100, "admin%", "paid"}
+	if wantQuery != gotQuery {
+		t.Errorf("got:\n%s\nwant:\n%s", gotQuery, wantQuery)
+	}
+	if !reflect.DeepEqual(wantArgs, gotArgs) {
+		t.Errorf("want:\n%v\ngot:\n%v", wantArgs, gotArgs)
+	}
+
+	// the subquery is not bound to the outer query after building
+	sub := sqlb.NewQueryBuilder().
+		Select(sqlb.Func("1")).
+		From(orders).
+		Where(&sqls.Segment{
+			Raw:     "#c1=#c2",
+			Columns: []*sqls.TableColumn{orders.Column("user_id"), users.Column("id")},
+		})
+	_, _, err = sqlb.NewQueryBuilder().
+		Select(users.Column("id")).
+		From(users).
+		WhereExists(sub).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = sub.Build()
+	if err == nil || !strings.Contains(err.Error(), "table not found: 'u'") {
+		t.Errorf("want table not found error for the standalone subquery, got %v", err)
+	}
+
+	// invalid operators are reported, even in the groups
+	_, _, err = sqlb.NewQueryBuilder().
+		Select(users.Column("id")).
+		From(users).
+		Where2(users.Column("id"), "= 1 OR 1 =", 1).
+		WhereAll(func(g *sqlb.CondGroup) {
+			g.Where2(users.Column("id"), ";", 1)
+		}).
+		Build()
+	if err == nil || !strings.Contains(err.Error(), `invalid operator: "= 1 OR 1 ="`) || !strings.Contains(err.Error(), `invalid operator: ";"`) {
+		t.Errorf("want invalid operator errors, got %v", err)
+	}
+}
+
+func TestQueryBuilderUniqueJoins(t *testing.T) {
+	var (
+		users   = sqlb.NewTable("users", "u")
+		orgs    = sqlb.NewTable("orgs", "g")
+		regions = sqlb.NewTable("regions", "r")
+		orders  = sqlb.NewTable("orders", "o")
+	)
+	testCases := []struct {
+		name     string
+		distinct bool
+		ordersOn *sqls.Segment // the optional join of orders, if any
+		where    *sqls.Segment
+		want     string
+	}{
+		{
+			name: "unreferenced unique joins trimmed without distinct",
+			want: "SELECT u.id FROM users AS u",
+		},
+		{
+			name: "referenced unique joins kept with dependencies",
+			where: &sqls.Segment{
+				Raw:     "#c1=$1",
+				Columns: regions.Columns("name"),
+				Args:    []any{"eu"},
+			},
+			want: "SELECT u.id FROM users AS u LEFT JOIN orgs AS g ON g.id=u.org_id LEFT JOIN regions AS r ON r.id=g.region_id WHERE r.name=$1",
+		},
+		{
+			name:     "kept optional join keeps its unique dependencies",
+			ordersOn: eq(orders.Column("org_id"), orgs.Column("id")),
+			want:     "SELECT u.id FROM users AS u LEFT JOIN orgs AS g ON g.id=u.org_id LEFT JOIN orders AS o ON o.org_id=g.id",
+		},
+		{
+			name:     "distinct trims both",
+			distinct: true,
+			ordersOn: eq(orders.Column("org_id"), orgs.Column("id")),
+			want:     "SELECT DISTINCT u.id FROM users AS u",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := newQuery(sqlb.DialectPostgreSQL, users.Column("id")).
+				From(users).
+				LeftJoinUnique(orgs, eq(orgs.Column("id"), users.Column("org_id"))).
+				LeftJoinUnique(regions, eq(regions.Column("id"), orgs.Column("region_id"))).
+				Where(tc.where)
+			if tc.distinct {
+				q.Distinct()
+			}
+			if tc.ordersOn != nil {
+				q.LeftJoinOptional(orders, tc.ordersOn)
+			}
+			got, _, err := q.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+
+	// the failed unique joins are reported, rather than marking the
+	// existing joins as unique
+	for _, q := range []*sqlb.QueryBuilder{
+		newQuery(sqlb.DialectPostgreSQL, users.Column("id")).
+			From(users).
+			LeftJoinOptional(orders, eq(orders.Column("user_id"), users.Column("id"))).
+			LeftJoinUnique(orders, eq(orders.Column("id"), users.Column("last_order_id"))),
+		newQuery(sqlb.DialectPostgreSQL, users.Column("id")).
+			From(users).
+			LeftJoinOptionalQuery(sqlb.NewQueryBuilder().Select(orders.Column("user_id")).From(orders), "o2", nil).
+			LeftJoinUniqueQuery(sqlb.NewQueryBuilder().Select(orders.Column("id")).From(orders), "o2", nil),
+	} {
+		_, _, err := q.Build()
+		if err == nil || !strings.Contains(err.Error(), "is already joined") {
+			t.Fatalf("want error %q, got %v", "is already joined", err)
+		}
+	}
+}
+
+func TestQueryBuilderRelations(t *testing.T) {
+	var (
+		users     = sqlb.NewTable("users", "u")
+		orgs      = sqlb.NewTable("orgs", "g")
+		regions   = sqlb.NewTable("regions", "r")
+		orders    = sqlb.NewTable("orders", "o")
+		addresses = sqlb.NewTable("addresses", "a")
+	)
+	relations := sqlb.NewRelations(
+		&sqlb.Relation{From: users, FromColumn: "org_id", To: orgs, ToColumn: "id"},
+		&sqlb.Relation{From: orgs, FromColumn: "region_id", To: regions, ToColumn: "id"},
+		&sqlb.Relation{From: users, FromColumn: "id", To: orders, ToColumn: "user_id", Cardinality: sqlb.OneToMany},
+	)
+	pg := sqlb.DialectPostgreSQL
+	testCases := []struct {
+		name    string
+		query   *sqlb.QueryBuilder
+		want    string
+		wantErr string
+	}{
+		{
+			name: "joins along the shortest path",
+			query: newQuery(pg).Relations(relations).From(users).
+				Select(users.Column("id")).
+				Where2(regions.Column("name"), "=", "eu"),
+			want: "SELECT u.id FROM users AS u LEFT JOIN orgs AS g ON g.id=u.org_id LEFT JOIN regions AS r ON r.id=g.region_id WHERE r.name=$1",
+		},
+		{
+			name: "reverse direction",
+			query: newQuery(pg).
+				Relations(relations).
+				Select(regions.Column("name"), orders.Column("id")).
+				From(regions),
+			want: "SELECT r.name, o.id FROM regions AS r LEFT JOIN orgs AS g ON g.region_id=r.id LEFT JOIN users AS u ON u.org_id=g.id LEFT JOIN orders AS o ON o.user_id=u.id",
+		},
+		{
+			name: "explicit joins are reused",
+			query: newQuery(pg).Relations(relations).From(users).
+				InnerJoin(orgs, eq(orgs.Column("id"), users.Column("org_id"))).
+				Select(regions.Column("name")),
+			want: "SELECT r.name FROM users AS u INNER JOIN orgs AS g ON g.id=u.org_id LEFT JOIN regions AS r ON r.id=g.region_id",
+		},
+		{
+			name: "count trims the unique joins referenced by selects only",
+			query: newQuery(pg).Relations(relations).From(users).
+				Select(users.Column("id"), regions.Column("name")).
+				Where2(orgs.Column("name"), "=", "acme").
+				CountBuilder(),
+			want: "SELECT COUNT(*) FROM users AS u LEFT JOIN orgs AS g ON g.id=u.org_id WHERE g.name=$1",
+		},
+		{
+			name: "inner join relation",
+			query: newQuery(pg).
+				Relations(sqlb.NewRelations(
+					&sqlb.Relation{From: users, FromColumn: "org_id", To: orgs, ToColumn: "id", Join: sqlb.JoinInner},
+				)).
+				Select(users.Column("id"), orgs.Column("name")).
+				From(users),
+			want: "SELECT u.id, g.name FROM users AS u INNER JOIN orgs AS g ON g.id=u.org_id",
+		},
+		{
+			name: "ambiguous path",
+			query: newQuery(pg).Relations(relations).From(users).
+				Relations(sqlb.NewRelations(
+					&sqlb.Relation{From: users, FromColumn: "org_id", To: orgs, ToColumn: "id"},
+					&sqlb.Relation{From: orgs, FromColumn: "region_id", To: regions, ToColumn: "id"},
+					&sqlb.Relation{From: users, FromColumn: "address_id", To: addresses, ToColumn: "id"},
+					&sqlb.Relation{From: addresses, FromColumn: "region_id", To: regions, ToColumn: "id"},
+				)).
+				Select(regions.Column("name")),
+			wantErr: "ambiguous join paths to table 'r'",
+		},
+		{
+			name: "undeclared table",
+			query: newQuery(pg).Relations(relations).From(users).
+				Select(addresses.Column("city")),
+			wantErr: "table not found: 'a'",
+		},
+		{
+			name: "invalid relation",
+			query: newQuery(pg).Relations(relations).From(users).
+				Relations(sqlb.NewRelations(&sqlb.Relation{From: users, To: orgs})).
+				Select(users.Column("id")),
+			wantErr: "incomplete relation",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, _, err := tc.query.Build()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("want error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+
+	// building and deriving never add the joins to the base builder
+	base := newQuery(pg).Relations(relations).From(users).Select(users.Column("id")).Where2(regions.Column("name"), "=", "eu")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := base.Build(); err != nil {
+				t.Error(err)
+			}
+			if _, _, err := base.CountBuilder().Build(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	got, _, err := base.InnerJoin(orgs, eq(orgs.Column("id"), users.Column("org_id"))).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SELECT u.id FROM users AS u INNER JOIN orgs AS g ON g.id=u.org_id LEFT JOIN regions AS r ON r.id=g.region_id WHERE r.name=$1"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	ambiguous := newQuery(pg).Relations(relations).From(users).
+		Relations(sqlb.NewRelations(
+			&sqlb.Relation{From: users, FromColumn: "org_id", To: orgs, ToColumn: "id"},
+			&sqlb.Relation{From: users, FromColumn: "owner_org_id", To: orgs, ToColumn: "id"},
+		)).
+		Select(orgs.Column("name"))
+	if _, _, err := ambiguous.CountBuilder().Build(); err == nil {
+		t.Error("want ambiguous error for the count builder")
+	}
+	_, _, err = ambiguous.Build()
+	if err == nil || strings.Contains(err.Error(), "collected errors") {
+		t.Errorf("want only the ambiguous error of the base builder, got %v", err)
+	}
+}
+
+func TestQueryBuilderSchema(t *testing.T) {
+	var (
+		users = sqlb.NewTable("users", "u").WithSchema(sqlb.NewSchema(
+			&sqlb.ColumnDef{Name: "id", Type: reflect.TypeOf(int64(0)), PrimaryKey: true},
+			&sqlb.ColumnDef{Name: "name", Type: reflect.TypeOf("")},
+			&sqlb.ColumnDef{Name: "org_id", Type: reflect.TypeOf(int64(0)), Nullable: true},
+		))
+		orgs   = sqlb.NewTable("orgs", "g")
+		orders = sqlb.NewTable("orders", "o")
+	)
+	testCases := []struct {
+		name    string
+		query   *sqlb.QueryBuilder
+		want    string
+		wantErr string
+	}{
+		{
+			name: "declared columns",
+			query: sqlb.NewQueryBuilder().
+				BindVar(syntax.Dollar).
+				Select(users.AllColumns()...).
+				From(users).
+				Where2(users.Column("name"), "=", "alice"),
+			want: "SELECT u.id, u.name, u.org_id FROM users AS u WHERE u.name=$1",
+		},
+		{
+			name: "expressions and tables without schema are not checked",
+			query: sqlb.NewQueryBuilder().
+				Select(users.Expression("COUNT(#t1.whatever)"), orgs.Column("anything")).
+				From(users).
+				InnerJoin(orgs, &sqls.Segment{
+					Raw:     "#c1=#c2",
+					Columns: []*sqls.TableColumn{orgs.Column("id"), users.Column("org_id")},
+				}),
+			want: "SELECT COUNT(u.whatever), g.anything FROM users AS u INNER JOIN orgs AS g ON g.id=u.org_id",
+		},
+		{
+			name: "typo in select",
+			query: sqlb.NewQueryBuilder().
+				Select(users.Column("nmae")).
+				From(users),
+			wantErr: "column 'nmae' is not declared in table 'users'",
+		},
+		{
+			name: "typo in join condition",
+			query: sqlb.NewQueryBuilder().
+				Select(users.Column("id")).
+				From(users).
+				InnerJoin(orgs, &sqls.Segment{
+					Raw:     "#c1=#c2",
+					Columns: []*sqls.TableColumn{orgs.Column("id"), users.Column("orgid")},
+				}),
+			wantErr: "column 'orgid' is not declared in table 'users'",
+		},
+		{
+			name: "typo with alias in correlated subquery",
+			query: sqlb.NewQueryBuilder().
+				Select(users.WithAlias("u2").Column("id")).
+				From(users.WithAlias("u2")).
+				WhereExists(sqlb.NewQueryBuilder().
+					Select(sqlb.Func("1")).
+					From(orders).
+					Where(&sqls.Segment{
+						Raw:     "#c1=#c2",
+						Columns: []*sqls.TableColumn{orders.Column("user_id"), users.WithAlias("u2").Column("uid")},
+					}),
+				),
+			wantErr: "column 'uid' is not declared in table 'users'",
+		},
+		{
+			name: "same table joined with and without schema",
+			query: sqlb.NewQueryBuilder().
+				Select(users.Column("id")).
+				From(users).
+				LeftJoin(sqlb.NewTable("users", "u"), &sqls.Segment{Raw: "1=1"}),
+			wantErr: "table [users AS u] is already joined",
+		},
+		{
+			name: "same table joined without and with schema",
+			query: sqlb.NewQueryBuilder().
+				Select(users.Column("id")).
+				From(sqlb.NewTable("users", "u")).
+				LeftJoin(users, &sqls.Segment{Raw: "1=1"}),
+			wantErr: "table [users AS u] is already joined",
+		},
+		{
+			name: "invalid schema",
+			query: func() *sqlb.QueryBuilder {
+				users := sqlb.NewTable("users", "u").WithSchema(sqlb.NewSchema(
+					&sqlb.ColumnDef{Name: "id"},
+					&sqlb.ColumnDef{Name: "id"},
+				))
+				return sqlb.NewQueryBuilder().
+					Select(users.Column("id")).
+					From(users)
+			}(),
+			wantErr: "column 'id' is declared more than once",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, _, err := tc.query.Build()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("want error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
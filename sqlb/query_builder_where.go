@@ -1,9 +1,6 @@
 package sqlb
 
-import (
-	"github.com/qjebbs/go-sqls"
-	"github.com/qjebbs/go-sqls/util"
-)
+import "github.com/qjebbs/go-sqls"
 
 // Where add a condition.  e.g.:
 //
@@ -31,29 +28,147 @@ func (b *QueryBuilder) Where(s *sqls.Segment) *QueryBuilder {
 //		Columns: []Column{column},
 //		Args: []any{1},
 //	})
+//
+// The op is one of =, <>, !=, <, <=, >, >=, LIKE, NOT LIKE, ILIKE, NOT ILIKE,
+// IS DISTINCT FROM and IS NOT DISTINCT FROM.
 func (b *QueryBuilder) Where2(column *sqls.TableColumn, op string, arg any) *QueryBuilder {
-	b.conditions.AppendSegments(&sqls.Segment{
-		Raw:     "#c1" + op + "$1",
-		Columns: []*sqls.TableColumn{column},
-		Args:    []any{arg},
-	})
+	s, err := where2(column, op, arg)
+	if err != nil {
+		b.pushError(err)
+		return b
+	}
+	b.conditions.AppendSegments(s)
 	return b
 }
 
 // WhereIn adds a where IN condition like `t.id IN (1,2,3)`
 func (b *QueryBuilder) WhereIn(column *sqls.TableColumn, list any) *QueryBuilder {
-	return b.Where(&sqls.Segment{
-		Raw:     "#c1 IN (#join('#$', ', '))",
-		Columns: []*sqls.TableColumn{column},
-		Args:    util.Args(list),
-	})
+	return b.Where(whereIn(column, list))
 }
 
 // WhereNotIn adds a where NOT IN condition like `t.id NOT IN (1,2,3)`
 func (b *QueryBuilder) WhereNotIn(column *sqls.TableColumn, list any) *QueryBuilder {
-	return b.Where(&sqls.Segment{
-		Raw:     "#c1 NOT IN (#join('#$', ', '))",
-		Columns: []*sqls.TableColumn{column},
-		Args:    util.Args(list),
-	})
+	return b.Where(whereNotIn(column, list))
+}
+
+// WhereBetween adds a where BETWEEN condition like `t.age BETWEEN 18 AND 30`
+func (b *QueryBuilder) WhereBetween(column *sqls.TableColumn, from, to any) *QueryBuilder {
+	return b.Where(whereBetween(column, from, to))
+}
+
+// WhereNull adds a where condition like `t.deleted_at IS NULL`
+func (b *QueryBuilder) WhereNull(column *sqls.TableColumn) *QueryBuilder {
+	return b.Where(whereNull(column, false))
+}
+
+// WhereNotNull adds a where condition like `t.deleted_at IS NOT NULL`
+func (b *QueryBuilder) WhereNotNull(column *sqls.TableColumn) *QueryBuilder {
+	return b.Where(whereNull(column, true))
+}
+
+// WhereLike adds a where LIKE condition, which matches the value literally
+// according to the mode, with the wildcards '%' and '_' escaped. e.g.:
+//
+//	b.WhereLike(t.Column("name"), "50%_off", sqlb.LikeContains)
+//	// t.name LIKE $1 ESCAPE '!', with arg '%50!%!_off%'
+//
+// Note that the '[' of SQL Server, which starts a character class, is not
+// escaped.
+func (b *QueryBuilder) WhereLike(column *sqls.TableColumn, value string, mode LikeMode) *QueryBuilder {
+	s, err := whereLike(column, value, mode)
+	if err != nil {
+		b.pushError(err)
+		return b
+	}
+	return b.Where(s)
+}
+
+// WhereExists adds a where EXISTS condition of the subquery, which can
+// reference the tables of b, e.g.:
+//
+//	b.WhereExists(
+//		sqlb.NewQueryBuilder().
+//			Select(sqlb.Func("1")).
+//			From(orders).
+//			Where(&sqls.Segment{
+//				Raw:     "#c1=#c2",
+//				Columns: []*sqls.TableColumn{orders.Column("user_id"), users.Column("id")},
+//			}),
+//	)
+//	// EXISTS (SELECT 1 FROM orders AS o WHERE o.user_id=u.id)
+//
+// The tables of b referenced by the subquery are counted in the dependency
+// calculation.
+func (b *QueryBuilder) WhereExists(builder sqls.Builder) *QueryBuilder {
+	return b.Where(whereExists(builder, false))
+}
+
+// WhereNotExists adds a where NOT EXISTS condition of the subquery, see
+// WhereExists().
+func (b *QueryBuilder) WhereNotExists(builder sqls.Builder) *QueryBuilder {
+	return b.Where(whereExists(builder, true))
+}
+
+// WhereInQuery adds a where IN condition of the subquery like
+// `t.id IN (SELECT ...)`, see WhereExists().
+func (b *QueryBuilder) WhereInQuery(column *sqls.TableColumn, builder sqls.Builder) *QueryBuilder {
+	return b.Where(whereInQuery(column, builder))
+}
+
+// WhereAny adds a group of conditions joined with OR, which can be nested
+// to any depth, see CondGroup. e.g.:
+//
+//	b.WhereAny(func(g *sqlb.CondGroup) {
+//		g.Where2(t.Column("status"), "=", "draft").
+//			WhereIn(t.Column("owner_id"), ids)
+//	})
+//	// WHERE (t.status=$1 OR t.owner_id IN ($2, $3))
+func (b *QueryBuilder) WhereAny(fn func(g *CondGroup)) *QueryBuilder {
+	return b.whereGroup(newCondGroup(" OR ", false, fn))
+}
+
+// WhereAll adds a group of conditions joined with AND, which is useful to
+// be nested in the groups of WhereAny().
+func (b *QueryBuilder) WhereAll(fn func(g *CondGroup)) *QueryBuilder {
+	return b.whereGroup(newCondGroup(" AND ", false, fn))
+}
+
+// WhereNot adds a group of conditions joined with AND and negated with NOT.
+func (b *QueryBuilder) WhereNot(fn func(g *CondGroup)) *QueryBuilder {
+	return b.whereGroup(newCondGroup(" AND ", true, fn))
+}
+
+func (b *QueryBuilder) whereGroup(g *CondGroup) *QueryBuilder {
+	for _, err := range g.errors {
+		b.pushError(err)
+	}
+	return b.Where(g.segment())
+}
+
+// Having add a condition of the groups.  e.g.:
+//
+//	b.Having(&sqls.Segment{
+//		Raw: "COUNT(#c1) > $1",
+//		Columns: t.Columns("id"),
+//		Args: []any{1},
+//	})
+func (b *QueryBuilder) Having(s *sqls.Segment) *QueryBuilder {
+	if s == nil {
+		return b
+	}
+	b.havings.AppendSegments(s)
+	return b
+}
+
+// Having2 is a helper func similar to Having(), which adds a simple having condition. e.g.:
+//
+//	b.Having2(t.Expression("SUM(#t1.amount)"), ">", 100)
+func (b *QueryBuilder) Having2(column *sqls.TableColumn, op string, arg any) *QueryBuilder {
+	s, err := where2(column, op, arg)
+	if err != nil {
+		b.pushError(err)
+		return b
+	}
+	b.havings.AppendSegments(s)
+	return b
 }
@@ -1,17 +1,212 @@
 package sqlb
 
-import "github.com/qjebbs/go-sqls"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qjebbs/go-sqls"
+)
 
 // With adds a segment as common table expression, the built query of s should be a subquery.
-func (b *QueryBuilder) With(name sqls.Table, builder sqls.Builder) *QueryBuilder {
-	b.ctes = append(b.ctes, &cte{
+//
+// The CTE is rendered only if it's referenced by the query, or by another
+// rendered CTE. The options can be used to declare the column list and
+// the materialization hint, e.g.:
+//
+//	b.With("t", query, sqlb.CTEColumns("id", "name"), sqlb.CTEMaterialized())
+//	// WITH t (id, name) AS MATERIALIZED (SELECT ...)
+func (b *QueryBuilder) With(name sqls.Table, builder sqls.Builder, options ...CTEOption) *QueryBuilder {
+	c := &cte{
 		table:   NewTable(name, ""),
 		Builder: builder,
-	})
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	b.ctes = append(b.ctes, c)
 	return b
 }
 
+// WithRecursive adds a recursive common table expression, which is rendered
+// as "name (columns) AS (anchor UNION ALL recursive)", and makes the WITH
+// clause "WITH RECURSIVE" except for SQL Server and Oracle. The columns
+// are required by some databases, and can be nil if not.
+//
+// For example:
+//
+//	b.WithRecursive("tree", []string{"id", "parent_id"},
+//		&sqls.Segment{Raw: "SELECT id, parent_id FROM nodes WHERE id = $1", Args: []any{1}},
+//		&sqls.Segment{Raw: "SELECT n.id, n.parent_id FROM nodes n JOIN tree t ON n.parent_id = t.id"},
+//	)
+func (b *QueryBuilder) WithRecursive(name sqls.Table, columns []string, anchor, recursive sqls.Builder, options ...CTEOption) *QueryBuilder {
+	if anchor == nil || recursive == nil {
+		b.pushError(fmt.Errorf("recursive CTE '%s': both anchor and recursive queries are required", name))
+		return b
+	}
+	options = append([]CTEOption{CTEColumns(columns...)}, options...)
+	b.With(name, &sqls.Segment{
+		Raw:      "#b1 UNION ALL #b2",
+		Builders: []sqls.Builder{anchor, recursive},
+	}, options...)
+	b.ctes[len(b.ctes)-1].recursive = true
+	return b
+}
+
+// CTEOption is the option of common table expression.
+type CTEOption func(c *cte)
+
+// CTEColumns declares the column list of the CTE.
+func CTEColumns(columns ...string) CTEOption {
+	return func(c *cte) {
+		c.columns = columns
+	}
+}
+
+// CTEMaterialized adds the MATERIALIZED hint to the CTE, which is supported
+// by PostgreSQL 12+.
+func CTEMaterialized() CTEOption {
+	return func(c *cte) {
+		c.materialized = "MATERIALIZED"
+	}
+}
+
+// CTENotMaterialized adds the NOT MATERIALIZED hint to the CTE, which is
+// supported by PostgreSQL 12+.
+func CTENotMaterialized() CTEOption {
+	return func(c *cte) {
+		c.materialized = "NOT MATERIALIZED"
+	}
+}
+
 type cte struct {
-	table Table
+	table        Table
+	columns      []string // the column list
+	materialized string   // the materialization hint
+	recursive    bool     // the CTE is recursive
 	sqls.Builder
 }
+
+// requiredCTEs returns the CTEs required by the tables of dep, including the
+// ones referenced by other required CTEs. The CTEs are sorted so that each
+// one comes after the CTEs it depends on, and otherwise in the declaration
+// order.
+func (b *QueryBuilder) requiredCTEs(dep map[Table]bool) []*cte {
+	byName := make(map[sqls.Table]*cte, len(b.ctes))
+	for _, c := range b.ctes {
+		byName[c.table.Name] = c
+	}
+	ctes := make([]*cte, 0, len(b.ctes))
+	visited := make(map[*cte]bool)
+	var visit func(c *cte)
+	visit = func(c *cte) {
+		if visited[c] {
+			// added, or being visited by a cyclic reference
+			return
+		}
+		visited[c] = true
+		for _, name := range referencedNames(c.Builder) {
+			dep[NewTable(name, "")] = true
+			if ref, ok := byName[name]; ok && ref != c {
+				visit(ref)
+			}
+		}
+		ctes = append(ctes, c)
+	}
+	for _, c := range b.ctes {
+		if dep[c.table] {
+			visit(c)
+		}
+	}
+	return ctes
+}
+
+// referencedNames returns the table names referenced by the builders,
+// which is used to find the CTEs they depend on.
+func referencedNames(builders ...sqls.Builder) []sqls.Table {
+	names := make([]sqls.Table, 0)
+	for _, builder := range builders {
+		switch b := builder.(type) {
+		case *QueryBuilder:
+			for _, t := range b.tables {
+				if t.Name != "" {
+					names = append(names, t.Name)
+				}
+				if from, ok := b.froms[t]; ok && from.Source != nil {
+					names = append(names, referencedNames(from.Source.Builders...)...)
+				}
+			}
+			for _, c := range b.ctes {
+				names = append(names, referencedNames(c.Builder)...)
+			}
+			names = append(names, referencedNames(b.unions...)...)
+		case *CompoundBuilder:
+			names = append(names, referencedNames(b.first)...)
+			for _, arm := range b.arms {
+				names = append(names, referencedNames(arm.builder)...)
+			}
+		case *sqls.Segment:
+			if b == nil {
+				continue
+			}
+			names = append(names, b.Tables...)
+			names = append(names, columnTables(b.Columns)...)
+			for _, s := range b.Segments {
+				names = append(names, referencedNames(s)...)
+			}
+			names = append(names, referencedNames(b.Builders...)...)
+		}
+	}
+	return names
+}
+
+func columnTables(columns []*sqls.TableColumn) []sqls.Table {
+	names := make([]sqls.Table, 0, len(columns))
+	for _, c := range columns {
+		if c == nil {
+			continue
+		}
+		if c.Table != "" {
+			names = append(names, c.Table)
+		}
+		names = append(names, columnTables(c.Columns)...)
+	}
+	return names
+}
+
+func (b *QueryBuilder) buildCTEs(ctx *sqls.Context, dep map[Table]bool) (string, error) {
+	if len(b.ctes) == 0 {
+		return "", nil
+	}
+	ctes := b.requiredCTEs(dep)
+	clauses := make([]string, 0, len(ctes))
+	recursive := false
+	for _, cte := range ctes {
+		query, err := cte.BuildContext(ctx)
+		if err != nil {
+			return "", fmt.Errorf("build CTE '%s': %w", cte.table.Name, err)
+		}
+		if query == "" {
+			continue
+		}
+		recursive = recursive || cte.recursive
+		sb := new(strings.Builder)
+		sb.WriteString(string(cte.table.Name))
+		if len(cte.columns) > 0 {
+			sb.WriteString(" (" + strings.Join(cte.columns, ", ") + ")")
+		}
+		sb.WriteString(" AS ")
+		if cte.materialized != "" {
+			sb.WriteString(cte.materialized + " ")
+		}
+		sb.WriteString("(" + query + ")")
+		clauses = append(clauses, sb.String())
+	}
+	if len(clauses) == 0 {
+		return "", nil
+	}
+	keyword := "WITH "
+	if recursive && b.dialect != DialectSQLServer && b.dialect != DialectOracle {
+		keyword = "WITH RECURSIVE "
+	}
+	return keyword + strings.Join(clauses, ", "), nil
+}
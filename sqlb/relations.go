@@ -0,0 +1,117 @@
+package sqlb
+
+import (
+	"fmt"
+
+	"github.com/qjebbs/go-sqls"
+)
+
+// JoinType is the join type of a relation.
+type JoinType uint
+
+// join types
+const (
+	JoinLeft JoinType = iota
+	JoinInner
+)
+
+// Cardinality is the cardinality of a relation, from the From table to the
+// To table.
+type Cardinality uint
+
+// cardinalities
+const (
+	ManyToOne Cardinality = iota
+	OneToOne
+	OneToMany
+)
+
+// Relation is a foreign-key relationship between two tables, which can be
+// traversed in both directions.
+type Relation struct {
+	From        Table       // the table of the foreign key
+	FromColumn  string      // the foreign key column
+	To          Table       // the referenced table
+	ToColumn    string      // the referenced column
+	Join        JoinType    // the join type, LEFT JOIN by default
+	Cardinality Cardinality // the cardinality from From to To, ManyToOne by default
+}
+
+// Relations is the graph of the relationships between tables, which is
+// declared once and used by QueryBuilder to add the joins automatically,
+// see QueryBuilder.Relations(). e.g.:
+//
+//	relations := sqlb.NewRelations(
+//		&sqlb.Relation{From: users, FromColumn: "org_id", To: orgs, ToColumn: "id"},
+//		&sqlb.Relation{From: orgs, FromColumn: "region_id", To: regions, ToColumn: "id"},
+//	)
+//
+// The tables are matched by their applied names, i.e. aliases, so a table
+// should be declared with the same alias as the queries use.
+type Relations struct {
+	edges map[sqls.Table][]*relationEdge // edges by the applied name of the table
+
+	errorList // errors during declaring
+}
+
+// relationEdge is a directed edge of the graph.
+type relationEdge struct {
+	to     Table
+	on     *sqls.Segment
+	join   string
+	unique bool // the joined table matches at most one row
+}
+
+// NewRelations returns a new Relations with the relations.
+func NewRelations(relations ...*Relation) *Relations {
+	r := &Relations{
+		edges: make(map[sqls.Table][]*relationEdge),
+	}
+	for _, rel := range relations {
+		r.Add(rel)
+	}
+	return r
+}
+
+// Add adds a relation to the graph.
+func (r *Relations) Add(rel *Relation) *Relations {
+	if rel == nil {
+		return r
+	}
+	if rel.From.Name == "" || rel.To.Name == "" || rel.FromColumn == "" || rel.ToColumn == "" {
+		r.pushError(fmt.Errorf("incomplete relation: %s.%s -> %s.%s", rel.From.AppliedName(), rel.FromColumn, rel.To.AppliedName(), rel.ToColumn))
+		return r
+	}
+	if rel.Join > JoinInner {
+		r.pushError(fmt.Errorf("invalid join type: %d", rel.Join))
+		return r
+	}
+	if rel.Cardinality > OneToMany {
+		r.pushError(fmt.Errorf("invalid cardinality: %d", rel.Cardinality))
+		return r
+	}
+	join := "LEFT JOIN"
+	if rel.Join == JoinInner {
+		join = "INNER JOIN"
+	}
+	from, to := rel.From.Column(rel.FromColumn), rel.To.Column(rel.ToColumn)
+	r.edges[rel.From.AppliedName()] = append(r.edges[rel.From.AppliedName()], &relationEdge{
+		to: rel.To,
+		on: &sqls.Segment{
+			Raw:     "#c1=#c2",
+			Columns: []*sqls.TableColumn{to, from},
+		},
+		join:   join,
+		unique: rel.Cardinality != OneToMany,
+	})
+	r.edges[rel.To.AppliedName()] = append(r.edges[rel.To.AppliedName()], &relationEdge{
+		to: rel.From,
+		on: &sqls.Segment{
+			Raw:     "#c1=#c2",
+			Columns: []*sqls.TableColumn{from, to},
+		},
+		join:   join,
+		unique: rel.Cardinality != ManyToOne,
+	})
+	return r
+}
@@ -0,0 +1,213 @@
+package sqlb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/qjebbs/go-sqls"
+)
+
+// ColumnDef declares a column of the table schema.
+type ColumnDef struct {
+	Name       string       // the column name
+	Type       reflect.Type // the Go type of the column, e.g. reflect.TypeOf(int64(0))
+	Nullable   bool         // the column accepts NULL
+	PrimaryKey bool         // the column is (part of) the primary key
+}
+
+// Schema is the declared columns of a table, which is used to validate
+// the column names on building, see Table.WithSchema().
+type Schema struct {
+	columns []*ColumnDef
+	byName  map[string]*ColumnDef
+
+	errorList // errors during declaring
+}
+
+// NewSchema returns a new Schema with the columns.
+func NewSchema(columns ...*ColumnDef) *Schema {
+	s := &Schema{
+		columns: make([]*ColumnDef, 0, len(columns)),
+		byName:  make(map[string]*ColumnDef, len(columns)),
+	}
+	for _, c := range columns {
+		if c == nil {
+			continue
+		}
+		if c.Name == "" {
+			s.pushError(fmt.Errorf("column name is empty"))
+			continue
+		}
+		if _, ok := s.byName[c.Name]; ok {
+			s.pushError(fmt.Errorf("column '%s' is declared more than once", c.Name))
+			continue
+		}
+		s.columns = append(s.columns, c)
+		s.byName[c.Name] = c
+	}
+	return s
+}
+
+// Columns returns the declared columns in order.
+func (s *Schema) Columns() []*ColumnDef {
+	if s == nil {
+		return nil
+	}
+	return s.columns
+}
+
+// Column returns the declared column by name.
+func (s *Schema) Column(name string) (*ColumnDef, bool) {
+	if s == nil {
+		return nil, false
+	}
+	c, ok := s.byName[name]
+	return c, ok
+}
+
+// PrimaryKey returns the primary key columns in order.
+func (s *Schema) PrimaryKey() []*ColumnDef {
+	if s == nil {
+		return nil
+	}
+	r := make([]*ColumnDef, 0, 1)
+	for _, c := range s.columns {
+		if c.PrimaryKey {
+			r = append(r, c)
+		}
+	}
+	return r
+}
+
+// checkColumns reports the columns of the tables with schema, which are
+// not declared in the schema. Only the columns created by Table.Column()
+// and Table.Columns() are checked, the expressions are left as they are.
+func (f *fromTables) checkColumns(segments ...*sqls.Segment) error {
+	for _, s := range segments {
+		if s == nil {
+			continue
+		}
+		if err := f.checkTableColumns(s.Columns, s.Raw); err != nil {
+			return err
+		}
+		if err := f.checkColumns(s.Segments...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fromTables) checkTableColumns(columns []*sqls.TableColumn, raw string) error {
+	for i, c := range columns {
+		if c == nil {
+			continue
+		}
+		if err := f.checkColumn(c); err != nil {
+			return fmt.Errorf("#column%d '%s' of '%s': %w", i+1, c.Raw, raw, err)
+		}
+		if err := f.checkTableColumns(c.Columns, c.Raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fromTables) checkColumn(c *sqls.TableColumn) error {
+	if !strings.HasPrefix(c.Raw, "#t1.") {
+		return nil
+	}
+	name := strings.TrimPrefix(c.Raw, "#t1.")
+	if name == "*" || !isIdentifier(name) {
+		return nil
+	}
+	t, from, ok := f.lookup(c.Table)
+	if !ok || from.Schema == nil {
+		return nil
+	}
+	if err := from.Schema.anyError(); err != nil {
+		return fmt.Errorf("schema of table '%s': %w", t.Name, err)
+	}
+	if _, ok := from.Schema.Column(name); !ok {
+		return fmt.Errorf("column '%s' is not declared in table '%s'", name, t.Name)
+	}
+	return nil
+}
+
+// checkColumnNames reports the column names of the main table, e.g. the
+// columns of INSERT and the SET items of UPDATE, which are not declared in
+// its schema.
+func (f *fromTables) checkColumnNames(names ...string) error {
+	if len(f.tables) == 0 {
+		return nil
+	}
+	t := f.tables[0]
+	from, ok := f.froms[t]
+	if !ok || from.Schema == nil {
+		return nil
+	}
+	if err := from.Schema.anyError(); err != nil {
+		return fmt.Errorf("schema of table '%s': %w", t.Name, err)
+	}
+	for _, name := range names {
+		if !isIdentifier(name) {
+			continue
+		}
+		if _, ok := from.Schema.Column(name); !ok {
+			return fmt.Errorf("column '%s' is not declared in table '%s'", name, t.Name)
+		}
+	}
+	return nil
+}
+
+// joinConditions returns the join conditions of the tables.
+func (f *fromTables) joinConditions() []*sqls.Segment {
+	segments := make([]*sqls.Segment, 0, len(f.tables))
+	for _, t := range f.tables {
+		if from, ok := f.froms[t]; ok && from.On != nil {
+			segments = append(segments, from.On)
+		}
+	}
+	return segments
+}
+
+// lookup returns the table declared in f or its outer queries.
+func (f *fromTables) lookup(name sqls.Table) (Table, *fromTable, bool) {
+	if f == nil {
+		return Table{}, nil, false
+	}
+	if t, ok := f.appliedNames[name]; ok {
+		if from, ok := f.froms[t]; ok {
+			return t, from, true
+		}
+	}
+	return f.outer.lookup(name)
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// columnSegments returns the segments of b whose columns are validated,
+// including the join conditions.
+func (b *QueryBuilder) columnSegments() []*sqls.Segment {
+	segments := []*sqls.Segment{
+		b.selects,
+		b.distinctOn,
+		b.touches,
+		b.conditions,
+		b.orders,
+		b.groupbys,
+		b.havings,
+		b.windows,
+	}
+	return append(segments, b.joinConditions()...)
+}
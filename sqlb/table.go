@@ -2,9 +2,16 @@ package sqlb
 
 import "github.com/qjebbs/go-sqls"
 
-// Table is the table name with alias.
+// Table is the table name with alias. It can be serialized to JSON / YAML,
+// e.g.: {"name": "users", "alias": "u"}
+//
+// The table can optionally carry a schema, see WithSchema(), which is not
+// serialized.
 type Table struct {
-	Name, Alias sqls.Table
+	Name  sqls.Table `json:"name" yaml:"name"`
+	Alias sqls.Table `json:"alias,omitempty" yaml:"alias,omitempty"`
+
+	schema *Schema
 }
 
 // NewTable returns a new Table.
@@ -17,10 +24,59 @@ func NewTable(name, alias sqls.Table) Table {
 
 // WithAlias returns a new Table with updated alias.
 func (t Table) WithAlias(alias sqls.Table) Table {
+	return Table{
+		Name:   t.Name,
+		Alias:  alias,
+		schema: t.schema,
+	}
+}
+
+// key returns the table without schema, which identifies the table in the
+// builders, so that the same table with or without schema is not joined
+// twice.
+func (t Table) key() Table {
 	return Table{
 		Name:  t.Name,
-		Alias: alias,
+		Alias: t.Alias,
+	}
+}
+
+// WithSchema returns a new Table with the schema, whose columns are
+// validated on building by all the builders. The checked columns are the
+// ones created by Column() / Columns(), wherever they're referenced, and
+// the column names of INSERT, UPDATE SET and the upsert clauses. The
+// expressions like Expression("COUNT(#t1.id)") are not checked. e.g.:
+//
+//	users := sqlb.NewTable("users", "u").WithSchema(sqlb.NewSchema(
+//		&sqlb.ColumnDef{Name: "id", Type: reflect.TypeOf(int64(0)), PrimaryKey: true},
+//		&sqlb.ColumnDef{Name: "name", Type: reflect.TypeOf("")},
+//	))
+//	b.Select(users.Column("nmae")).From(users)
+//	// Build(): column 'nmae' is not declared in table 'users'
+func (t Table) WithSchema(s *Schema) Table {
+	return Table{
+		Name:   t.Name,
+		Alias:  t.Alias,
+		schema: s,
+	}
+}
+
+// Schema returns the schema of the table, nil if not declared.
+func (t Table) Schema() *Schema {
+	return t.schema
+}
+
+// AllColumns returns all the columns declared in the schema, in order.
+// It returns nil if the table has no schema.
+func (t Table) AllColumns() []*sqls.TableColumn {
+	if t.schema == nil {
+		return nil
+	}
+	r := make([]*sqls.TableColumn, 0, len(t.schema.columns))
+	for _, c := range t.schema.columns {
+		r = append(r, t.Column(c.Name))
 	}
+	return r
 }
 
 // AppliedName returns the alias if it is not empty, otherwise returns the name.
@@ -0,0 +1,341 @@
+package sqlb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+var _ sqls.Builder = (*UpdateBuilder)(nil)
+
+// UpdateBuilder is the SQL UPDATE statement builder.
+//
+// The joined tables are rendered according to the dialect:
+//
+//   - PostgreSQL, SQLite: UPDATE t SET ... FROM t2 JOIN t3 ON ... WHERE ...
+//   - MySQL: UPDATE t JOIN t2 ON ... SET ... WHERE ...
+//   - SQL Server: UPDATE t SET ... FROM t JOIN t2 ON ... WHERE ...
+//
+// The optional joins are trimmed if no SET, WHERE or RETURNING references them.
+type UpdateBuilder struct {
+	bindVarStyle syntax.BindVarStyle // the bindvar style
+	dialect      Dialect             // the SQL dialect
+
+	fromTables // the target and join tables
+
+	sets       *sqls.Segment // set items, joined with comma.
+	setNames   []string      // the column names of Set()
+	conditions *sqls.Segment // where conditions, joined with AND.
+	returning  *sqls.Segment // returning columns
+
+	allowFullTable bool // allow UPDATE without WHERE
+
+	errorList // errors during building
+}
+
+// NewUpdateBuilder returns a new UpdateBuilder.
+func NewUpdateBuilder() *UpdateBuilder {
+	return &UpdateBuilder{
+		fromTables: newFromTables(),
+		sets: &sqls.Segment{
+			Prefix: "SET",
+			Raw:    "#join('#segment', ', ')",
+		},
+		conditions: &sqls.Segment{
+			Prefix: "WHERE",
+			Raw:    "#join('#segment', ' AND ')",
+		},
+		returning: &sqls.Segment{
+			Prefix: "RETURNING",
+			Raw:    "#join('#column', ', ')",
+		},
+	}
+}
+
+// Table set the table to update.
+func (b *UpdateBuilder) Table(t Table) *UpdateBuilder {
+	if err := b.setFrom(t); err != nil {
+		b.pushError(fmt.Errorf("update table is empty"))
+	}
+	return b
+}
+
+// Set adds a SET item, the value of *sqls.Segment is rendered as an
+// expression rather than an arg. e.g.:
+//
+//	b.Set("name", "alice")
+//	b.Set("updated_at", &sqls.Segment{Raw: "NOW()"})
+func (b *UpdateBuilder) Set(column string, value any) *UpdateBuilder {
+	b.setNames = append(b.setNames, column)
+	if s, ok := value.(*sqls.Segment); ok {
+		b.sets.AppendSegments(&sqls.Segment{
+			Raw:      column + " = #s1",
+			Segments: []*sqls.Segment{s},
+		})
+		return b
+	}
+	b.sets.AppendSegments(&sqls.Segment{
+		Raw:  column + " = $1",
+		Args: []any{value},
+	})
+	return b
+}
+
+// SetExpr adds a SET item of the expression. e.g.:
+//
+//	b.SetExpr(&sqls.Segment{
+//		Raw:     "count = #c1 + $1",
+//		Columns: t.Columns("count"),
+//		Args:    []any{1},
+//	})
+func (b *UpdateBuilder) SetExpr(s *sqls.Segment) *UpdateBuilder {
+	if s == nil {
+		return b
+	}
+	b.sets.AppendSegments(s)
+	return b
+}
+
+// From append a table, whose relation to the updated table is specified
+// by the WHERE conditions.
+func (b *UpdateBuilder) From(t Table) *UpdateBuilder {
+	return b.join("CROSS JOIN", t, nil, false)
+}
+
+// InnerJoin append a inner join table.
+func (b *UpdateBuilder) InnerJoin(t Table, on *sqls.Segment) *UpdateBuilder {
+	return b.join("INNER JOIN", t, on, false)
+}
+
+// LeftJoin append a left join table.
+func (b *UpdateBuilder) LeftJoin(t Table, on *sqls.Segment) *UpdateBuilder {
+	return b.join("LEFT JOIN", t, on, false)
+}
+
+// LeftJoinOptional append a left join table, and mark it as optional, which
+// is trimmed if no SET, WHERE or RETURNING references it.
+//
+// Make sure all columns referenced in the query are reflected in
+// *sqls.Segment.Columns, so that the *UpdateBuilder can calculate the
+// dependency correctly.
+func (b *UpdateBuilder) LeftJoinOptional(t Table, on *sqls.Segment) *UpdateBuilder {
+	return b.join("LEFT JOIN", t, on, true)
+}
+
+func (b *UpdateBuilder) join(joinStr string, t Table, on *sqls.Segment, optional bool) *UpdateBuilder {
+	if err := b.fromTables.join(joinStr, t, on, optional, false); err != nil {
+		b.pushError(err)
+	}
+	return b
+}
+
+// Where add a condition.  e.g.:
+//
+//	b.Where(&sqls.Segment{
+//		Raw: "#c1 = $1",
+//		Columns: t.Columns("id"),
+//		Args: []any{1},
+//	})
+func (b *UpdateBuilder) Where(s *sqls.Segment) *UpdateBuilder {
+	if s == nil {
+		return b
+	}
+	b.conditions.AppendSegments(s)
+	return b
+}
+
+// Where2 is a helper func similar to Where(), which adds a simple where condition. e.g.:
+//
+//	b.Where2(column, "=", 1)
+func (b *UpdateBuilder) Where2(column *sqls.TableColumn, op string, arg any) *UpdateBuilder {
+	s, err := where2(column, op, arg)
+	if err != nil {
+		b.pushError(err)
+		return b
+	}
+	return b.Where(s)
+}
+
+// WhereIn adds a where IN condition like `t.id IN (1,2,3)`
+func (b *UpdateBuilder) WhereIn(column *sqls.TableColumn, list any) *UpdateBuilder {
+	return b.Where(whereIn(column, list))
+}
+
+// WhereNotIn adds a where NOT IN condition like `t.id NOT IN (1,2,3)`
+func (b *UpdateBuilder) WhereNotIn(column *sqls.TableColumn, list any) *UpdateBuilder {
+	return b.Where(whereNotIn(column, list))
+}
+
+// Returning set the RETURNING columns, which is not supported by MySQL
+// and SQL Server.
+func (b *UpdateBuilder) Returning(columns ...*sqls.TableColumn) *UpdateBuilder {
+	b.returning.WithColumns(columns...)
+	return b
+}
+
+// AllowFullTable allows to build the UPDATE without WHERE conditions,
+// which updates all the rows of the table.
+func (b *UpdateBuilder) AllowFullTable() *UpdateBuilder {
+	b.allowFullTable = true
+	return b
+}
+
+// BindVar set the bindvar style.
+func (b *UpdateBuilder) BindVar(style syntax.BindVarStyle) *UpdateBuilder {
+	b.bindVarStyle = style
+	return b
+}
+
+// Dialect set the SQL dialect, which decides how the joins are rendered.
+func (b *UpdateBuilder) Dialect(d Dialect) *UpdateBuilder {
+	b.dialect = d
+	return b
+}
+
+// Build builds the query.
+func (b *UpdateBuilder) Build() (query string, args []any, err error) {
+	args = make([]any, 0)
+	ctx := sqls.NewContext(&args)
+	ctx.BindVarStyle = b.bindVarStyle
+	query, err = b.buildInternal(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	return query, args, nil
+}
+
+// BuildContext builds the query with the context.
+func (b *UpdateBuilder) BuildContext(ctx *sqls.Context) (query string, err error) {
+	return b.buildInternal(ctx)
+}
+
+func (b *UpdateBuilder) buildInternal(ctx *sqls.Context) (string, error) {
+	if b == nil {
+		return "", nil
+	}
+	if err := b.anyError(); err != nil {
+		return "", err
+	}
+	if len(b.tables) == 0 || b.tables[0].Name == "" {
+		return "", fmt.Errorf("no table to update")
+	}
+	if len(b.sets.Segments) == 0 {
+		return "", fmt.Errorf("no columns to update")
+	}
+	if len(b.conditions.Segments) == 0 && !b.allowFullTable {
+		return "", fmt.Errorf("UPDATE without WHERE is not allowed, call AllowFullTable() to confirm")
+	}
+	if len(b.returning.Columns) > 0 && (b.dialect == DialectMySQL || b.dialect == DialectSQLServer) {
+		return "", fmt.Errorf("RETURNING is not supported by %s", b.dialect)
+	}
+	if err := b.checkColumnNames(b.setNames...); err != nil {
+		return "", err
+	}
+	segments := []*sqls.Segment{b.sets, b.conditions, b.returning}
+	if err := b.checkColumns(append(segments, b.joinConditions()...)...); err != nil {
+		return "", err
+	}
+	dep, err := b.dependencies(b.sets, b.conditions, b.returning)
+	if err != nil {
+		return "", err
+	}
+	joins := b.requiredJoins(dep)
+	target := b.tables[0]
+	var clauses []string
+	switch b.dialect {
+	case DialectPostgreSQL, DialectSQLite:
+		clauses, err = b.buildUpdateFrom(ctx, target, joins)
+	case DialectMySQL:
+		clauses, err = b.buildUpdateJoin(ctx, target, joins)
+	case DialectSQLServer:
+		clauses, err = b.buildUpdateServer(ctx, target, joins)
+	default:
+		err = fmt.Errorf("UPDATE is not supported by %s", b.dialect)
+	}
+	if err != nil {
+		return "", err
+	}
+	returning, err := b.returning.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if returning != "" {
+		clauses = append(clauses, returning)
+	}
+	return strings.Join(clauses, " "), nil
+}
+
+// buildUpdateFrom builds "UPDATE t SET ... FROM t2 JOIN t3 ON ... WHERE ...",
+// where the join condition of t2 is moved to the WHERE clause.
+func (b *UpdateBuilder) buildUpdateFrom(ctx *sqls.Context, target Table, joins []Table) ([]string, error) {
+	clauses := []string{"UPDATE " + b.froms[target].Segment.Raw}
+	set, err := b.sets.BuildContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	clauses = append(clauses, set)
+	from, conditions, err := b.buildJoinsAsFrom(ctx, "FROM", b.dialect, joins, b.conditions)
+	if err != nil {
+		return nil, err
+	}
+	if from != "" {
+		clauses = append(clauses, from)
+	}
+	where, err := conditions.BuildContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if where != "" {
+		clauses = append(clauses, where)
+	}
+	return clauses, nil
+}
+
+// buildUpdateJoin builds "UPDATE t JOIN t2 ON ... SET ... WHERE ...".
+func (b *UpdateBuilder) buildUpdateJoin(ctx *sqls.Context, target Table, joins []Table) ([]string, error) {
+	tables, err := b.buildTables(ctx, target, joins)
+	if err != nil {
+		return nil, err
+	}
+	clauses := []string{"UPDATE " + tables}
+	set, err := b.sets.BuildContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	clauses = append(clauses, set)
+	where, err := b.conditions.BuildContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if where != "" {
+		clauses = append(clauses, where)
+	}
+	return clauses, nil
+}
+
+// buildUpdateServer builds "UPDATE alias SET ... FROM t AS alias JOIN t2 ON ... WHERE ...".
+func (b *UpdateBuilder) buildUpdateServer(ctx *sqls.Context, target Table, joins []Table) ([]string, error) {
+	clauses := []string{"UPDATE " + string(target.AppliedName())}
+	set, err := b.sets.BuildContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	clauses = append(clauses, set)
+	if target.Alias != "" || len(joins) > 0 {
+		tables, err := b.buildTables(ctx, target, joins)
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, "FROM "+tables)
+	}
+	where, err := b.conditions.BuildContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if where != "" {
+		clauses = append(clauses, where)
+	}
+	return clauses, nil
+}
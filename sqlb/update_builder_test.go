@@ -0,0 +1,232 @@
+package sqlb_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/sqlb"
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+func TestUpdateBuilder(t *testing.T) {
+	var (
+		users  = sqlb.NewTable("users", "u")
+		orders = sqlb.NewTable("orders", "o")
+		groups = sqlb.NewTable("groups", "g")
+	)
+	testCases := []struct {
+		name      string
+		builder   *sqlb.UpdateBuilder
+		wantQuery string
+		wantArgs  []any
+	}{
+		{
+			name: "postgres",
+			builder: sqlb.NewUpdateBuilder().
+				BindVar(syntax.Dollar).
+				Dialect(sqlb.DialectPostgreSQL).
+				Table(users).
+				InnerJoin(orders, eq(orders.Column("user_id"), users.Column("id"))).
+				LeftJoinOptional(groups, eq(groups.Column("id"), orders.Column("group_id"))).
+				Set("vip", true).
+				SetExpr(&sqls.Segment{
+					Raw:     "updated_at = #c1",
+					Columns: orders.Columns("created_at"),
+				}).
+				Where2(orders.Column("amount"), ">", 100).
+				Returning(users.Column("id")),
+			wantQuery: "UPDATE users AS u SET vip = $1, updated_at = o.created_at FROM orders AS o WHERE o.user_id=u.id AND o.amount>$2 RETURNING u.id",
+			wantArgs:  []any{true, 100},
+		},
+		{
+			name: "mysql",
+			builder: sqlb.NewUpdateBuilder().
+				BindVar(syntax.Dollar).
+				Dialect(sqlb.DialectMySQL).
+				Table(users).
+				InnerJoin(orders, eq(orders.Column("user_id"), users.Column("id"))).
+				LeftJoinOptional(groups, eq(groups.Column("id"), orders.Column("group_id"))).
+				Set("vip", true).
+				SetExpr(&sqls.Segment{
+					Raw:     "updated_at = #c1",
+					Columns: orders.Columns("created_at"),
+				}).
+				Where2(orders.Column("amount"), ">", 100),
+			wantQuery: "UPDATE users AS u INNER JOIN orders AS o ON o.user_id=u.id SET vip = $1, updated_at = o.created_at WHERE o.amount>$2",
+			wantArgs:  []any{true, 100},
+		},
+		{
+			name: "sqlserver",
+			builder: sqlb.NewUpdateBuilder().
+				BindVar(syntax.Dollar).
+				Dialect(sqlb.DialectSQLServer).
+				Table(users).
+				InnerJoin(orders, eq(orders.Column("user_id"), users.Column("id"))).
+				LeftJoinOptional(groups, eq(groups.Column("id"), orders.Column("group_id"))).
+				Set("vip", true).
+				SetExpr(&sqls.Segment{
+					Raw:     "updated_at = #c1",
+					Columns: orders.Columns("created_at"),
+				}).
+				Where2(orders.Column("amount"), ">", 100),
+			wantQuery: "UPDATE u SET vip = $1, updated_at = o.created_at FROM users AS u INNER JOIN orders AS o ON o.user_id=u.id WHERE o.amount>$2",
+			wantArgs:  []any{true, 100},
+		},
+		{
+			name: "optional join referenced",
+			builder: sqlb.NewUpdateBuilder().
+				BindVar(syntax.Dollar).
+				Dialect(sqlb.DialectPostgreSQL).
+				Table(users).
+				InnerJoin(orders, eq(orders.Column("user_id"), users.Column("id"))).
+				LeftJoinOptional(groups, eq(groups.Column("id"), orders.Column("group_id"))).
+				Set("vip", true).
+				SetExpr(&sqls.Segment{
+					Raw:     "updated_at = #c1",
+					Columns: orders.Columns("created_at"),
+				}).
+				Where2(orders.Column("amount"), ">", 100).
+				Where2(groups.Column("name"), "=", "gold"),
+			wantQuery: "UPDATE users AS u SET vip = $1, updated_at = o.created_at FROM orders AS o LEFT JOIN groups AS g ON g.id=o.group_id WHERE o.user_id=u.id AND o.amount>$2 AND g.name=$3",
+			wantArgs:  []any{true, 100, "gold"},
+		},
+		{
+			name: "later join references target",
+			builder: sqlb.NewUpdateBuilder().
+				BindVar(syntax.Dollar).
+				Table(users).
+				InnerJoin(orders, eq(orders.Column("user_id"), users.Column("id"))).
+				InnerJoin(groups, &sqls.Segment{
+					Raw:     "#c1=#c2 AND #c3=#c4",
+					Columns: []*sqls.TableColumn{groups.Column("id"), users.Column("group_id"), groups.Column("owner_id"), orders.Column("seller_id")},
+				}).
+				Set("vip", true).
+				Where2(groups.Column("name"), "=", "gold"),
+			wantQuery: "UPDATE users AS u SET vip = $1 FROM orders AS o CROSS JOIN groups AS g WHERE o.user_id=u.id AND g.id=u.group_id AND g.owner_id=o.seller_id AND g.name=$2",
+			wantArgs:  []any{true, "gold"},
+		},
+		{
+			name: "full table",
+			builder: sqlb.NewUpdateBuilder().
+				BindVar(syntax.Question).
+				Table(sqlb.NewTable("users", "")).
+				Set("updated_at", &sqls.Segment{Raw: "NOW()"}).
+				AllowFullTable(),
+			wantQuery: "UPDATE users SET updated_at = NOW()",
+			wantArgs:  []any{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotQuery, gotArgs, err := tc.builder.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotQuery != tc.wantQuery {
+				t.Errorf("got:\n%s\nwant:\n%s", gotQuery, tc.wantQuery)
+			}
+			if !reflect.DeepEqual(gotArgs, tc.wantArgs) {
+				t.Errorf("got:\n%#v\nwant:\n%#v", gotArgs, tc.wantArgs)
+			}
+		})
+	}
+}
+
+func TestUpdateBuilderErrors(t *testing.T) {
+	var (
+		users  = sqlb.NewTable("users", "u")
+		orders = sqlb.NewTable("orders", "o")
+		groups = sqlb.NewTable("groups", "g")
+		typed  = users.WithSchema(sqlb.NewSchema(
+			&sqlb.ColumnDef{Name: "id", Type: reflect.TypeOf(int64(0)), PrimaryKey: true},
+			&sqlb.ColumnDef{Name: "name", Type: reflect.TypeOf("")},
+		))
+	)
+	testCases := []struct {
+		name    string
+		builder *sqlb.UpdateBuilder
+		wantErr string
+	}{
+		{
+			name:    "no table",
+			builder: sqlb.NewUpdateBuilder().Set("name", "alice").AllowFullTable(),
+			wantErr: "no table to update",
+		},
+		{
+			name:    "no sets",
+			builder: sqlb.NewUpdateBuilder().Table(users).AllowFullTable(),
+			wantErr: "no columns to update",
+		},
+		{
+			name:    "no where",
+			builder: sqlb.NewUpdateBuilder().Table(users).Set("name", "alice"),
+			wantErr: "UPDATE without WHERE is not allowed",
+		},
+		{
+			name:    "undeclared set column",
+			builder: sqlb.NewUpdateBuilder().Table(typed).Set("nmae", "alice").Where2(typed.Column("id"), "=", 1),
+			wantErr: "column 'nmae' is not declared in table 'users'",
+		},
+		{
+			name: "undeclared join column",
+			builder: sqlb.NewUpdateBuilder().
+				Table(typed).
+				InnerJoin(orders, eq(orders.Column("user_id"), typed.Column("uid"))).
+				Set("name", "alice").
+				Where2(orders.Column("amount"), ">", 100),
+			wantErr: "column 'uid' is not declared in table 'users'",
+		},
+		{
+			name: "postgres left join",
+			builder: sqlb.NewUpdateBuilder().
+				Table(users).
+				LeftJoin(orders, eq(orders.Column("user_id"), users.Column("id"))).
+				Set("name", "alice").
+				AllowFullTable(),
+			wantErr: "LEFT JOIN to the target table is not supported by PostgreSQL",
+		},
+		{
+			name: "postgres later left join references target",
+			builder: sqlb.NewUpdateBuilder().
+				Table(users).
+				InnerJoin(orders, eq(orders.Column("user_id"), users.Column("id"))).
+				LeftJoin(groups, eq(groups.Column("id"), users.Column("group_id"))).
+				Set("name", "alice").
+				AllowFullTable(),
+			wantErr: "LEFT JOIN to the target table is not supported by PostgreSQL",
+		},
+		{
+			name: "mysql returning",
+			builder: sqlb.NewUpdateBuilder().
+				Dialect(sqlb.DialectMySQL).
+				Table(users).
+				Set("name", "alice").
+				Where2(users.Column("id"), "=", 1).
+				Returning(users.Column("id")),
+			wantErr: "RETURNING is not supported by MySQL",
+		},
+		{
+			name: "collected errors",
+			builder: sqlb.NewUpdateBuilder().
+				Table(users).
+				From(orders).
+				From(orders).
+				Set("name", "alice").
+				AllowFullTable(),
+			wantErr: "table [orders AS o] is already joined",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := tc.builder.Build()
+			if err == nil {
+				t.Fatal("want error, got nil")
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %q, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package sqlb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/util"
+)
+
+// operators is the whitelist of the operators accepted by Where2(), the
+// value tells if the operator is a keyword, which is separated by spaces.
+var operators = map[string]bool{
+	"=":                    false,
+	"<>":                   false,
+	"!=":                   false,
+	"<":                    false,
+	"<=":                   false,
+	">":                    false,
+	">=":                   false,
+	"LIKE":                 true,
+	"NOT LIKE":             true,
+	"ILIKE":                true,
+	"NOT ILIKE":            true,
+	"IS DISTINCT FROM":     true,
+	"IS NOT DISTINCT FROM": true,
+}
+
+// where2 returns the condition segment of Where2() of the builders.
+func where2(column *sqls.TableColumn, op string, arg any) (*sqls.Segment, error) {
+	normalized := strings.ToUpper(strings.Join(strings.Fields(op), " "))
+	keyword, ok := operators[normalized]
+	if !ok {
+		return nil, fmt.Errorf("invalid operator: %q", op)
+	}
+	// keep the spaces of the symbol operators, e.g.: " = "
+	raw := "#c1" + op + "$1"
+	if keyword {
+		raw = "#c1 " + normalized + " $1"
+	}
+	return &sqls.Segment{
+		Raw:     raw,
+		Columns: []*sqls.TableColumn{column},
+		Args:    []any{arg},
+	}, nil
+}
+
+// whereIn returns the condition segment of WhereIn() of the builders.
+func whereIn(column *sqls.TableColumn, list any) *sqls.Segment {
+	return &sqls.Segment{
+		Raw:     "#c1 IN (#join('#$', ', '))",
+		Columns: []*sqls.TableColumn{column},
+		Args:    util.Args(list),
+	}
+}
+
+// whereNotIn returns the condition segment of WhereNotIn() of the builders.
+func whereNotIn(column *sqls.TableColumn, list any) *sqls.Segment {
+	return &sqls.Segment{
+		Raw:     "#c1 NOT IN (#join('#$', ', '))",
+		Columns: []*sqls.TableColumn{column},
+		Args:    util.Args(list),
+	}
+}
+
+// whereBetween returns the condition segment of WhereBetween() of the builders.
+func whereBetween(column *sqls.TableColumn, from, to any) *sqls.Segment {
+	return &sqls.Segment{
+		Raw:     "#c1 BETWEEN $1 AND $2",
+		Columns: []*sqls.TableColumn{column},
+		Args:    []any{from, to},
+	}
+}
+
+// whereNull returns the condition segment of WhereNull() of the builders.
+func whereNull(column *sqls.TableColumn, not bool) *sqls.Segment {
+	raw := "#c1 IS NULL"
+	if not {
+		raw = "#c1 IS NOT NULL"
+	}
+	return &sqls.Segment{
+		Raw:     raw,
+		Columns: []*sqls.TableColumn{column},
+	}
+}
+
+// LikeMode is the matching mode of WhereLike().
+type LikeMode uint
+
+// like modes
+const (
+	LikeContains LikeMode = iota // LIKE '%value%'
+	LikePrefix                   // LIKE 'value%'
+	LikeSuffix                   // LIKE '%value'
+)
+
+// likeEscaper escapes the wildcards of LIKE with '!', which has no special
+// meaning in the string literals of all the dialects, unlike '\'.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// whereLike returns the condition segment of WhereLike() of the builders.
+func whereLike(column *sqls.TableColumn, value string, mode LikeMode) (*sqls.Segment, error) {
+	pattern := likeEscaper.Replace(value)
+	switch mode {
+	case LikeContains:
+		pattern = "%" + pattern + "%"
+	case LikePrefix:
+		pattern = pattern + "%"
+	case LikeSuffix:
+		pattern = "%" + pattern
+	default:
+		return nil, fmt.Errorf("invalid like mode: %d", mode)
+	}
+	return &sqls.Segment{
+		Raw:     "#c1 LIKE $1 ESCAPE '!'",
+		Columns: []*sqls.TableColumn{column},
+		Args:    []any{pattern},
+	}, nil
+}
+
+// whereExists returns the condition segment of WhereExists() of the builders.
+func whereExists(builder sqls.Builder, not bool) *sqls.Segment {
+	raw := "EXISTS (#b1)"
+	if not {
+		raw = "NOT EXISTS (#b1)"
+	}
+	return &sqls.Segment{
+		Raw:      raw,
+		Builders: []sqls.Builder{builder},
+	}
+}
+
+// whereInQuery returns the condition segment of WhereInQuery() of the builders.
+func whereInQuery(column *sqls.TableColumn, builder sqls.Builder) *sqls.Segment {
+	return &sqls.Segment{
+		Raw:      "#c1 IN (#b1)",
+		Columns:  []*sqls.TableColumn{column},
+		Builders: []sqls.Builder{builder},
+	}
+}
@@ -0,0 +1,152 @@
+package sqlb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qjebbs/go-sqls"
+)
+
+// Window is the window specification of the window functions, which is
+// used by Over() for an inline window, or QueryBuilder.Window() for a
+// named window. e.g.:
+//
+//	w := sqlb.NewWindow().
+//		PartitionBy(users.Column("org_id")).
+//		OrderBy(orders.Column("created_at"), sqlb.Desc)
+//	rn, err := w.Over(sqlb.Func("ROW_NUMBER()"))
+//	if err != nil {
+//		return err
+//	}
+//	b.Select(rn)
+//	// ROW_NUMBER() OVER (PARTITION BY u.org_id ORDER BY o.created_at DESC)
+//
+// The tables of the partition and order columns are counted in the
+// dependency calculation.
+type Window struct {
+	partitions []*sqls.TableColumn
+	orders     []*sqls.TableColumn
+	frame      string
+
+	errorList // errors during declaring
+}
+
+// NewWindow returns a new Window.
+func NewWindow() *Window {
+	return &Window{}
+}
+
+// PartitionBy appends the partition columns.
+func (w *Window) PartitionBy(columns ...*sqls.TableColumn) *Window {
+	w.partitions = append(w.partitions, columns...)
+	return w
+}
+
+// OrderBy appends a sorting order.
+func (w *Window) OrderBy(column *sqls.TableColumn, order Order) *Window {
+	if order > DescNullsLast {
+		w.pushError(fmt.Errorf("invalid order: %d", order))
+		return w
+	}
+	w.orders = append(w.orders, &sqls.TableColumn{
+		Raw:     "#c1 " + orders[order],
+		Columns: []*sqls.TableColumn{column},
+	})
+	return w
+}
+
+// Frame set the frame clause, e.g.:
+//
+//	w.Frame("ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW")
+func (w *Window) Frame(frame string) *Window {
+	w.frame = frame
+	return w
+}
+
+// Over returns the column of the window function over the window, e.g.:
+//
+//	w.Over(sqlb.Func("SUM(#c1)", orders.Column("amount")))
+//	// SUM(o.amount) OVER (...)
+//
+// It returns the errors of the window, e.g. an invalid order.
+func (w *Window) Over(function *sqls.TableColumn) (*sqls.TableColumn, error) {
+	if err := w.anyError(); err != nil {
+		return nil, fmt.Errorf("window: %w", err)
+	}
+	spec, columns := w.spec(2)
+	return &sqls.TableColumn{
+		Raw:     "#c1 OVER (" + spec + ")",
+		Columns: append([]*sqls.TableColumn{function}, columns...),
+	}, nil
+}
+
+// spec returns the window specification, the columns of which are
+// referenced from #c{offset}.
+func (w *Window) spec(offset int) (string, []*sqls.TableColumn) {
+	clauses := make([]string, 0, 3)
+	columns := make([]*sqls.TableColumn, 0, len(w.partitions)+len(w.orders))
+	refs := func(cols []*sqls.TableColumn) string {
+		r := make([]string, 0, len(cols))
+		for _, c := range cols {
+			r = append(r, fmt.Sprintf("#c%d", offset+len(columns)))
+			columns = append(columns, c)
+		}
+		return strings.Join(r, ", ")
+	}
+	if len(w.partitions) > 0 {
+		clauses = append(clauses, "PARTITION BY "+refs(w.partitions))
+	}
+	if len(w.orders) > 0 {
+		clauses = append(clauses, "ORDER BY "+refs(w.orders))
+	}
+	if w.frame != "" {
+		clauses = append(clauses, w.frame)
+	}
+	return strings.Join(clauses, " "), columns
+}
+
+// OverWindow returns the column of the window function over the named
+// window declared by QueryBuilder.Window(), e.g.:
+//
+//	sqlb.OverWindow(sqlb.Func("RANK()"), "w")
+//	// RANK() OVER w
+func OverWindow(function *sqls.TableColumn, name string) *sqls.TableColumn {
+	return &sqls.TableColumn{
+		Raw:     "#c1 OVER " + name,
+		Columns: []*sqls.TableColumn{function},
+	}
+}
+
+// Func returns the column of a function expression, which references the
+// columns with #c1, #c2..., e.g.:
+//
+//	sqlb.Func("ROW_NUMBER()")
+//	sqlb.Func("COALESCE(SUM(#c1), 0)", orders.Column("amount"))
+func Func(expression string, columns ...*sqls.TableColumn) *sqls.TableColumn {
+	return &sqls.TableColumn{
+		Raw:     expression,
+		Columns: columns,
+	}
+}
+
+// Window declares a named window in the WINDOW clause, which can be
+// referenced by OverWindow(). e.g.:
+//
+//	b.Window("w", sqlb.NewWindow().PartitionBy(users.Column("org_id")))
+//	// WINDOW w AS (PARTITION BY u.org_id)
+func (b *QueryBuilder) Window(name string, w *Window) *QueryBuilder {
+	if name == "" || w == nil {
+		b.pushError(fmt.Errorf("window: empty name or specification"))
+		return b
+	}
+	if err := w.anyError(); err != nil {
+		b.pushError(fmt.Errorf("window '%s': %w", name, err))
+		return b
+	}
+	spec, columns := w.spec(1)
+	b.windows.AppendSegments(&sqls.Segment{
+		Raw:     name + " AS (" + spec + ")",
+		Columns: columns,
+	})
+	return b
+}
@@ -0,0 +1,47 @@
+package sqlfile_test
+
+import (
+	"fmt"
+	"testing/fstest"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/sqlfile"
+)
+
+func Example() {
+	// it's usually an embed.FS in real world, e.g.:
+	//
+	//	//go:embed queries/*.sql
+	//	var files embed.FS
+	files := fstest.MapFS{
+		"users.sql": &fstest.MapFile{Data: []byte(`
+-- name: GetUsers
+-- Get users by ids, with extra conditions.
+-- columns: 1
+-- segments: 1
+SELECT id, name FROM users
+WHERE #c1 IN (#join('#$', ', ')) AND #s1;
+`)},
+	}
+	queries := sqlfile.MustLoad(files, "*.sql")
+
+	var users sqls.Table = "users"
+	seg := queries.Segment("GetUsers")
+	seg.WithColumns(users.Expressions("id")...)
+	seg.WithArgs(1, 2, 3)
+	seg.WithSegments(&sqls.Segment{
+		Raw:     "#c1 = $1",
+		Columns: users.Expressions("active"),
+		Args:    []any{true},
+	})
+	query, args, err := seg.Build()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(query)
+	fmt.Println(args)
+	// Output:
+	// SELECT id, name FROM users
+	// WHERE id IN ($1, $2, $3) AND active = $4
+	// [1 2 3 true]
+}
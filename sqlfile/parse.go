@@ -0,0 +1,236 @@
+package sqlfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+// parseFile parses the queries in the content of file.
+func parseFile(file, content string) ([]*Query, error) {
+	queries := make([]*Query, 0)
+	var (
+		cur      *Query
+		body     []string
+		docs     []string
+		inHeader bool
+	)
+	flush := func() error {
+		if cur == nil {
+			return nil
+		}
+		cur.Doc = strings.Join(docs, "\n")
+		cur.Raw = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(strings.Join(body, "\n")), ";"))
+		if err := cur.check(); err != nil {
+			return fmt.Errorf("%s:%d: query '%s': %w", cur.File, cur.Line, cur.Name, err)
+		}
+		queries = append(queries, cur)
+		return nil
+	}
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		key, value, isDecl := parseComment(trimmed)
+		if isDecl && key == "name" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			if value == "" {
+				return nil, fmt.Errorf("%s:%d: empty query name", file, i+1)
+			}
+			cur = &Query{
+				Name:     value,
+				File:     file,
+				Line:     i + 1,
+				Args:     -1,
+				Columns:  -1,
+				Tables:   -1,
+				Segments: -1,
+				Builders: -1,
+			}
+			body, docs, inHeader = nil, nil, true
+			continue
+		}
+		if cur == nil {
+			if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: statement outside of named query", file, i+1)
+		}
+		if inHeader {
+			if trimmed == "" {
+				continue
+			}
+			if strings.HasPrefix(trimmed, "--") {
+				if !isDecl {
+					docs = append(docs, strings.TrimSpace(strings.TrimPrefix(trimmed, "--")))
+					continue
+				}
+				if err := cur.declare(key, value); err != nil {
+					return nil, fmt.Errorf("%s:%d: %w", file, i+1, err)
+				}
+				continue
+			}
+			inHeader = false
+		}
+		body = append(body, line)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// parseComment parses the declaration comment like "-- name: GetUsers".
+func parseComment(line string) (key, value string, ok bool) {
+	if !strings.HasPrefix(line, "--") {
+		return "", "", false
+	}
+	key, value, ok = strings.Cut(strings.TrimPrefix(line, "--"), ":")
+	if !ok {
+		return "", "", false
+	}
+	key = strings.ToLower(strings.TrimSpace(key))
+	switch key {
+	case "name", "args", "columns", "tables", "segments", "builders":
+		return key, strings.TrimSpace(value), true
+	}
+	return "", "", false
+}
+
+func (q *Query) declare(key, value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid %s count '%s'", key, value)
+	}
+	for _, c := range q.counts() {
+		if c.kind == key {
+			*c.n = n
+		}
+	}
+	return nil
+}
+
+// count is the declared count of a kind of references.
+type count struct {
+	kind string
+	n    *int
+}
+
+func (q *Query) counts() []count {
+	return []count{
+		{"args", &q.Args},
+		{"columns", &q.Columns},
+		{"tables", &q.Tables},
+		{"segments", &q.Segments},
+		{"builders", &q.Builders},
+	}
+}
+
+// refKinds maps the preprocessing functions to the kinds of references.
+var refKinds = map[string]string{
+	"$":       "args",
+	"?":       "args",
+	"c":       "columns",
+	"col":     "columns",
+	"column":  "columns",
+	"t":       "tables",
+	"table":   "tables",
+	"s":       "segments",
+	"seg":     "segments",
+	"segment": "segments",
+	"b":       "builders",
+	"builder": "builders",
+}
+
+// refs is the references of a kind in the template.
+type refs struct {
+	used map[int]bool // referenced indexes
+	max  int          // max referenced index
+	all  bool         // all are referenced by #join()
+}
+
+func (r *refs) add(index int) {
+	r.used[index] = true
+	if index > r.max {
+		r.max = index
+	}
+}
+
+// check parses the template, and checks the references against the
+// declarations, in the same way as a segment checks the usage on building.
+func (q *Query) check() error {
+	if q.Raw == "" {
+		return fmt.Errorf("empty query")
+	}
+	tree, err := syntax.Parse(q.Raw)
+	if err != nil {
+		return err
+	}
+	q.Tree = tree
+	all := make(map[string]*refs)
+	for _, kind := range refKinds {
+		all[kind] = &refs{used: make(map[int]bool)}
+	}
+	for _, expr := range tree.ExprList {
+		switch expr := expr.(type) {
+		case *syntax.BindVarExpr:
+			all["args"].add(expr.Index)
+		case *syntax.FuncCallExpr:
+			if expr.Name == "join" {
+				if len(expr.Args) != 2 {
+					return fmt.Errorf("bad args for #join(tmpl, sep string): got %v", expr.Args)
+				}
+				tmpl, err := syntax.Parse(expr.Args[0])
+				if err != nil {
+					return fmt.Errorf("parse join template '%s': %w", expr.Args[0], err)
+				}
+				for _, e := range tmpl.ExprList {
+					fn, ok := e.(*syntax.FuncExpr)
+					if !ok {
+						continue
+					}
+					kind, ok := refKinds[fn.Name]
+					if !ok {
+						return fmt.Errorf("function '%s' is not found", fn.Name)
+					}
+					all[kind].all = true
+				}
+				continue
+			}
+			kind, ok := refKinds[expr.Name]
+			if !ok {
+				return fmt.Errorf("function '%s' is not found", expr.Name)
+			}
+			if len(expr.Args) != 1 {
+				return fmt.Errorf("bad args for #%s(i int): got %v", expr.Name, expr.Args)
+			}
+			i, err := strconv.Atoi(expr.Args[0])
+			if err != nil || i < 1 {
+				return fmt.Errorf("invalid index '%s' for #%s", expr.Args[0], expr.Name)
+			}
+			all[kind].add(i)
+		}
+	}
+	for _, c := range q.counts() {
+		n := *c.n
+		if n < 0 {
+			continue
+		}
+		r := all[c.kind]
+		name := strings.TrimSuffix(c.kind, "s")
+		if r.max > n {
+			return fmt.Errorf("%s %d is referenced, but %d %s declared", name, r.max, n, c.kind)
+		}
+		if r.all {
+			continue
+		}
+		for i := 1; i <= n; i++ {
+			if !r.used[i] {
+				return fmt.Errorf("%s %d is declared but not referenced", name, i)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Package sqlfile loads named queries from annotated .sql files, which
+// makes it easy to maintain big queries out of Go code, e.g. with embed.FS.
+//
+// A file could contain many queries, each query starts with a name comment
+// and optionally header comments to declare the expected count of args,
+// columns, tables, segments and builders to be referenced:
+//
+//	-- name: GetUsers
+//	-- Get users by ids, with extra conditions.
+//	-- columns: 1
+//	-- segments: 1
+//	SELECT * FROM users WHERE #c1 IN (#join('#$', ', ')) AND #s1;
+//
+//	-- name: CountUsers
+//	SELECT COUNT(*) FROM users;
+//
+// The templates are parsed and checked against the declarations on loading,
+// so that a broken template fails at startup rather than on the first build.
+// The header comments other than declarations are kept as the doc of query.
+package sqlfile
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+
+	"github.com/qjebbs/go-sqls"
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+// Query is a named query loaded from .sql file.
+type Query struct {
+	Name string         // name of the query
+	Doc  string         // doc of the query, from header comments
+	File string         // file where the query is defined
+	Line int            // line where the query is defined
+	Raw  string         // the segment template
+	Tree *syntax.Clause // parsed template
+
+	// the declared counts of references, -1 if not declared.
+	Args, Columns, Tables, Segments, Builders int
+}
+
+// Segment returns a new segment of the query template, which is ready
+// to be filled with args, columns, etc.
+func (q *Query) Segment() *sqls.Segment {
+	return &sqls.Segment{Raw: q.Raw}
+}
+
+// Queries is the collection of loaded queries.
+type Queries struct {
+	queries map[string]*Query
+}
+
+// Load loads queries from files matching the patterns in fsys, see
+// fs.Glob for the pattern syntax. It loads all "*.sql" files in the root
+// of fsys if no pattern is given.
+func Load(fsys fs.FS, patterns ...string) (*Queries, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"*.sql"}
+	}
+	files := make([]string, 0)
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("pattern matches no files: %s", pattern)
+		}
+		files = append(files, matches...)
+	}
+	qs := &Queries{
+		queries: make(map[string]*Query),
+	}
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		queries, err := parseFile(file, string(content))
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range queries {
+			if prev, ok := qs.queries[q.Name]; ok {
+				return nil, fmt.Errorf("%s:%d: query '%s' redeclared, previous declaration at %s:%d", q.File, q.Line, q.Name, prev.File, prev.Line)
+			}
+			qs.queries[q.Name] = q
+		}
+	}
+	return qs, nil
+}
+
+// MustLoad is like Load, but panics if any error occurs. It's useful
+// for loading queries on initialization.
+func MustLoad(fsys fs.FS, patterns ...string) *Queries {
+	qs, err := Load(fsys, patterns...)
+	if err != nil {
+		panic(err)
+	}
+	return qs
+}
+
+// Get returns the query by name.
+func (qs *Queries) Get(name string) (*Query, error) {
+	q, ok := qs.queries[name]
+	if !ok {
+		return nil, fmt.Errorf("query '%s' not found", name)
+	}
+	return q, nil
+}
+
+// Segment returns a new segment of the named query, it panics if the
+// query is not found, since it's usually a programming error.
+func (qs *Queries) Segment(name string) *sqls.Segment {
+	q, err := qs.Get(name)
+	if err != nil {
+		panic(err)
+	}
+	return q.Segment()
+}
+
+// Names returns the sorted names of all queries.
+func (qs *Queries) Names() []string {
+	names := make([]string, 0, len(qs.queries))
+	for name := range qs.queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,116 @@
+package sqlfile_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/qjebbs/go-sqls/sqlfile"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	files := fstest.MapFS{
+		"a.sql": &fstest.MapFile{Data: []byte(`
+-- leading comments are ignored
+
+-- name: A
+-- Doc of A.
+-- args: 2
+SELECT * FROM a WHERE x = $1 AND y = $2;
+
+-- name: B
+-- columns: 2
+-- tables: 1
+SELECT #join('#c', ', ') FROM #t1
+`)},
+	}
+	qs, err := sqlfile.Load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(qs.Names(), ","), "A,B"; got != want {
+		t.Errorf("got names %s, want %s", got, want)
+	}
+	a, err := qs.Get("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Doc != "Doc of A." || a.Line != 4 || a.Args != 2 || a.Columns != -1 {
+		t.Errorf("unexpected query: %+v", a)
+	}
+	if a.Raw != "SELECT * FROM a WHERE x = $1 AND y = $2" {
+		t.Errorf("unexpected raw: %q", a.Raw)
+	}
+	if a.Tree == nil || len(a.Tree.ExprList) != 4 {
+		t.Errorf("unexpected tree: %v", a.Tree)
+	}
+	if _, err := qs.Get("C"); err == nil {
+		t.Error("want error for unknown query")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "outside",
+			content: "SELECT 1",
+			wantErr: "q.sql:1: statement outside of named query",
+		},
+		{
+			name:    "empty",
+			content: "-- name: A\n-- args: 1\n",
+			wantErr: "q.sql:1: query 'A': empty query",
+		},
+		{
+			name:    "syntax",
+			content: "-- name: A\nSELECT $1, ?",
+			wantErr: "mixed bindvar styles",
+		},
+		{
+			name:    "over referenced",
+			content: "-- name: A\n-- columns: 1\nSELECT #c1, #c2",
+			wantErr: "q.sql:1: query 'A': column 2 is referenced, but 1 columns declared",
+		},
+		{
+			name:    "not referenced",
+			content: "-- name: A\n-- segments: 2\nSELECT 1 WHERE #s2",
+			wantErr: "segment 1 is declared but not referenced",
+		},
+		{
+			name:    "unknown function",
+			content: "-- name: A\nSELECT #x1",
+			wantErr: "function 'x' is not found",
+		},
+		{
+			name:    "invalid count",
+			content: "-- name: A\n-- args: many\nSELECT 1",
+			wantErr: "q.sql:2: invalid args count 'many'",
+		},
+		{
+			name:    "redeclared",
+			content: "-- name: A\nSELECT 1\n-- name: A\nSELECT 2",
+			wantErr: "q.sql:3: query 'A' redeclared, previous declaration at q.sql:1",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := sqlfile.Load(fstest.MapFS{
+				"q.sql": &fstest.MapFile{Data: []byte(tc.content)},
+			})
+			if err == nil {
+				t.Fatalf("want error %q, got nil", tc.wantErr)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %q, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
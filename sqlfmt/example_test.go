@@ -0,0 +1,32 @@
+package sqlfmt_test
+
+import (
+	"fmt"
+
+	"github.com/qjebbs/go-sqls/sqlfmt"
+)
+
+func ExampleFormat() {
+	query := "WITH bar_type_1 AS (SELECT * FROM bar AS b WHERE b.type = $1) " +
+		"SELECT f.*, b1.* FROM foo AS f " +
+		"LEFT JOIN bar_type_1 AS b1 ON b1.foo_id = f.id " +
+		"WHERE f.name = 'a  (SELECT' AND f.id IN (SELECT foo_id FROM baz) " +
+		"ORDER BY f.id DESC LIMIT 10"
+	fmt.Println(sqlfmt.Format(query))
+	// Output:
+	// WITH bar_type_1 AS (
+	//   SELECT *
+	//   FROM bar AS b
+	//   WHERE b.type = $1
+	// )
+	// SELECT f.*, b1.*
+	// FROM foo AS f
+	//   LEFT JOIN bar_type_1 AS b1
+	//     ON b1.foo_id = f.id
+	// WHERE f.name = 'a  (SELECT' AND f.id IN (
+	//   SELECT foo_id
+	//   FROM baz
+	// )
+	// ORDER BY f.id DESC
+	// LIMIT 10
+}
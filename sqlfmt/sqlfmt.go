@@ -0,0 +1,376 @@
+// Package sqlfmt formats built SQL queries for human reading.
+//
+// It reflows the query by putting major clauses on their own lines, and
+// indenting common table expressions, subqueries and join conditions. The
+// quoted strings, comments and bindvars are kept intact, so it's safe to
+// format both the built and the interpolated queries, e.g.:
+//
+//	WITH bar_type_1 AS (
+//	  SELECT *
+//	  FROM bar AS b
+//	  WHERE b.type = $1
+//	)
+//	SELECT f.*, b1.*
+//	FROM foo AS f
+//	  LEFT JOIN bar_type_1 AS b1
+//	    ON b1.foo_id = f.id
+//	ORDER BY f.id DESC
+//	LIMIT 10
+package sqlfmt
+
+import (
+	"strings"
+
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+// Option is the option of Format.
+type Option func(*options)
+
+type options struct {
+	// Indent is the string used for one level of indentation.
+	Indent string
+}
+
+func defaultOptions() *options {
+	return &options{
+		Indent: "  ",
+	}
+}
+
+func applyOptions(opts []Option) *options {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithIndent sets the string used for one level of indentation, which is
+// two spaces by default.
+func WithIndent(indent string) Option {
+	return func(opts *options) {
+		opts.Indent = indent
+	}
+}
+
+// Format formats the query. It only changes whitespaces outside of the
+// quoted strings and comments, the formatted query is equivalent to the
+// input.
+func Format(query string, options ...Option) string {
+	opts := applyOptions(options)
+	f := &formatter{
+		indent: opts.Indent,
+		tokens: syntax.ScanSQL(query),
+	}
+	f.format()
+	return strings.TrimRight(string(f.buf), " \t\n")
+}
+
+type formatter struct {
+	indent string
+	tokens []*syntax.SQLToken
+
+	buf       []byte
+	level     int     // indentation level of current block
+	lineLevel int     // indentation level of current line
+	parens    []paren // open parentheses
+	space     bool    // whitespace pending to be written
+	lineStart bool    // nothing written since last line break
+	last      string  // last written token
+	prevWord  string  // previous word of current block, in upper case
+	joined    bool    // current block is in a join clause
+}
+
+// paren is an open parenthesis, it saves the states of the outer block.
+type paren struct {
+	subquery  bool
+	level     int
+	lineLevel int
+	prevWord  string
+	joined    bool
+}
+
+func (f *formatter) format() {
+	for i, t := range f.tokens {
+		switch t.Type {
+		case syntax.SQLSpace:
+			f.space = true
+		case syntax.SQLComment:
+			f.write(t.Text)
+			if strings.HasPrefix(t.Text, "--") {
+				f.newline(f.level)
+			}
+		case syntax.SQLWord:
+			f.word(i, t.Text)
+		case syntax.SQLPunct:
+			switch t.Text {
+			case "(":
+				f.open(i)
+			case ")":
+				f.close()
+			case ";":
+				f.write(t.Text)
+				f.newline(f.level)
+				f.prevWord, f.joined = "", false
+			default:
+				f.write(t.Text)
+			}
+		default:
+			f.write(t.Text)
+		}
+	}
+}
+
+var joinModifiers = map[string]bool{
+	"INNER":   true,
+	"LEFT":    true,
+	"RIGHT":   true,
+	"FULL":    true,
+	"CROSS":   true,
+	"NATURAL": true,
+	"OUTER":   true,
+	"LATERAL": true,
+}
+
+var clauses = map[string]bool{
+	"SELECT":    true,
+	"FROM":      true,
+	"WHERE":     true,
+	"HAVING":    true,
+	"WINDOW":    true,
+	"LIMIT":     true,
+	"OFFSET":    true,
+	"FETCH":     true,
+	"RETURNING": true,
+	"VALUES":    true,
+	"SET":       true,
+	"UNION":     true,
+	"INTERSECT": true,
+	"EXCEPT":    true,
+	"WITH":      true,
+	"INSERT":    true,
+	"UPDATE":    true,
+	"DELETE":    true,
+	"MERGE":     true,
+}
+
+func (f *formatter) word(i int, text string) {
+	upper := strings.ToUpper(text)
+	if f.atBlock() {
+		if level, ok := f.clauseLevel(i, upper); ok {
+			f.newline(level)
+		}
+		f.prevWord = upper
+	}
+	f.write(text)
+}
+
+// clauseLevel tells if the word starts a clause, and the indentation
+// level of the clause.
+func (f *formatter) clauseLevel(i int, upper string) (int, bool) {
+	switch upper {
+	case "FROM":
+		if f.prevWord == "DELETE" || f.isDistinctFrom(i) {
+			return 0, false
+		}
+	case "VALUES":
+		// MySQL: ON DUPLICATE KEY UPDATE a = VALUES(a)
+		if f.last == "=" || f.last == "," {
+			return 0, false
+		}
+	case "SET":
+		// ON CONFLICT DO UPDATE SET
+		if f.prevWord == "UPDATE" {
+			return 0, false
+		}
+	case "UPDATE":
+		switch f.prevWord {
+		case "FOR", "KEY", "DO", "THEN":
+			return 0, false
+		}
+	case "GROUP", "ORDER":
+		if f.nextWord(i) != "BY" {
+			return 0, false
+		}
+		f.joined = false
+		return f.level, true
+	case "FOR":
+		switch f.nextWord(i) {
+		case "UPDATE", "SHARE", "NO", "KEY":
+			f.joined = false
+			return f.level, true
+		}
+		return 0, false
+	case "WHEN":
+		next := f.nextWord(i)
+		if next != "MATCHED" && next != "NOT" {
+			return 0, false
+		}
+		return f.level, true
+	case "ON":
+		switch f.nextWord(i) {
+		case "CONFLICT", "DUPLICATE":
+			f.joined = false
+			return f.level, true
+		}
+		if f.joined {
+			return f.level + 2, true
+		}
+		return 0, false
+	case "JOIN", "APPLY":
+		if joinModifiers[f.prevWord] {
+			return 0, false
+		}
+		f.joined = true
+		return f.level + 1, true
+	default:
+		if joinModifiers[upper] && !joinModifiers[f.prevWord] {
+			// look ahead for the JOIN / APPLY keyword, e.g.: LEFT OUTER JOIN
+			for j := f.nextWordIndex(i); j >= 0; j = f.nextWordIndex(j) {
+				next := strings.ToUpper(f.tokens[j].Text)
+				if next == "JOIN" || next == "APPLY" {
+					f.joined = true
+					return f.level + 1, true
+				}
+				if !joinModifiers[next] {
+					break
+				}
+			}
+			return 0, false
+		}
+	}
+	if !clauses[upper] {
+		return 0, false
+	}
+	f.joined = false
+	return f.level, true
+}
+
+// nextWord returns the next word after tokens[i] in upper case, skipping
+// whitespaces and comments. It returns "" if the next token is not a word.
+func (f *formatter) nextWord(i int) string {
+	j := f.nextWordIndex(i)
+	if j < 0 {
+		return ""
+	}
+	return strings.ToUpper(f.tokens[j].Text)
+}
+
+func (f *formatter) nextWordIndex(i int) int {
+	j := f.nextIndex(i)
+	if j < 0 || f.tokens[j].Type != syntax.SQLWord {
+		return -1
+	}
+	return j
+}
+
+// isDistinctFrom tells if tokens[i] is the FROM of "IS [NOT] DISTINCT FROM".
+func (f *formatter) isDistinctFrom(i int) bool {
+	j := f.prevIndex(i)
+	if j < 0 || !strings.EqualFold(f.tokens[j].Text, "DISTINCT") {
+		return false
+	}
+	j = f.prevIndex(j)
+	if j >= 0 && strings.EqualFold(f.tokens[j].Text, "NOT") {
+		j = f.prevIndex(j)
+	}
+	return j >= 0 && strings.EqualFold(f.tokens[j].Text, "IS")
+}
+
+// prevIndex returns the index of previous token before tokens[i], skipping
+// whitespaces and comments.
+func (f *formatter) prevIndex(i int) int {
+	for j := i - 1; j >= 0; j-- {
+		switch f.tokens[j].Type {
+		case syntax.SQLSpace, syntax.SQLComment:
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// nextIndex returns the index of next token after tokens[i], skipping
+// whitespaces and comments.
+func (f *formatter) nextIndex(i int) int {
+	for j := i + 1; j < len(f.tokens); j++ {
+		switch f.tokens[j].Type {
+		case syntax.SQLSpace, syntax.SQLComment:
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+func (f *formatter) open(i int) {
+	p := paren{
+		level:     f.level,
+		lineLevel: f.lineLevel,
+		prevWord:  f.prevWord,
+		joined:    f.joined,
+	}
+	switch f.nextWord(i) {
+	case "SELECT", "WITH", "VALUES":
+		p.subquery = true
+	}
+	f.write("(")
+	f.parens = append(f.parens, p)
+	if !p.subquery {
+		return
+	}
+	f.level = f.lineLevel + 1
+	f.prevWord, f.joined = "", false
+	f.newline(f.level)
+}
+
+func (f *formatter) close() {
+	if len(f.parens) == 0 {
+		// unbalanced parentheses, keep it as is
+		f.write(")")
+		return
+	}
+	p := f.parens[len(f.parens)-1]
+	f.parens = f.parens[:len(f.parens)-1]
+	if p.subquery {
+		f.newline(p.lineLevel)
+		f.level = p.level
+	}
+	f.prevWord, f.joined = p.prevWord, p.joined
+	f.write(")")
+}
+
+// atBlock tells if current position is at the top level of a query or
+// subquery, where the clauses should be reflowed.
+func (f *formatter) atBlock() bool {
+	return len(f.parens) == 0 || f.parens[len(f.parens)-1].subquery
+}
+
+func (f *formatter) write(s string) {
+	if f.space && !f.lineStart && len(f.buf) > 0 {
+		f.buf = append(f.buf, ' ')
+	}
+	f.buf = append(f.buf, s...)
+	f.space = false
+	f.lineStart = false
+	f.last = s
+}
+
+// newline starts a new line with the indentation level, it does not
+// produce empty lines.
+func (f *formatter) newline(level int) {
+	for len(f.buf) > 0 && (f.buf[len(f.buf)-1] == ' ' || f.buf[len(f.buf)-1] == '\t') {
+		f.buf = f.buf[:len(f.buf)-1]
+	}
+	f.space = false
+	if len(f.buf) == 0 {
+		return
+	}
+	if f.buf[len(f.buf)-1] != '\n' {
+		f.buf = append(f.buf, '\n')
+	}
+	f.buf = append(f.buf, strings.Repeat(f.indent, level)...)
+	f.lineStart = true
+	f.lineLevel = level
+}
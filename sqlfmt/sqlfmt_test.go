@@ -0,0 +1,67 @@
+package sqlfmt_test
+
+import (
+	"testing"
+
+	"github.com/qjebbs/go-sqls/sqlfmt"
+)
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		query string
+		want  string
+	}{
+		{
+			query: "SELECT  a,\n\tb FROM t WHERE a = ? -- comment\nAND b = '--' /* ) */",
+			want:  "SELECT a, b\nFROM t\nWHERE a = ? -- comment\nAND b = '--' /* ) */",
+		},
+		{
+			query: "SELECT ROW_NUMBER() OVER (PARTITION BY a ORDER BY b), LEFT(c, 1) FROM t GROUP BY a",
+			want:  "SELECT ROW_NUMBER() OVER (PARTITION BY a ORDER BY b), LEFT(c, 1)\nFROM t\nGROUP BY a",
+		},
+		{
+			query: "SELECT a FROM t WHERE a = $1 UNION ALL (SELECT a FROM u)",
+			want:  "SELECT a\nFROM t\nWHERE a = $1\nUNION ALL (\n  SELECT a\n  FROM u\n)",
+		},
+		{
+			query: "SELECT * FROM t INNER JOIN u ON u.id = t.uid AND u.x IN (SELECT x FROM v) LEFT OUTER JOIN w ON w.id = t.wid",
+			want: "SELECT *\nFROM t\n  INNER JOIN u\n    ON u.id = t.uid AND u.x IN (\n      SELECT x\n      FROM v\n    )\n" +
+				"  LEFT OUTER JOIN w\n    ON w.id = t.wid",
+		},
+		{
+			query: "INSERT INTO t (a, b) VALUES ($1, $2) ON CONFLICT (a) DO UPDATE SET b = EXCLUDED.b RETURNING a",
+			want:  "INSERT INTO t (a, b)\nVALUES ($1, $2)\nON CONFLICT (a) DO UPDATE SET b = EXCLUDED.b\nRETURNING a",
+		},
+		{
+			query: "INSERT INTO t (a) VALUES (?) ON DUPLICATE KEY UPDATE a = VALUES(a)",
+			want:  "INSERT INTO t (a)\nVALUES (?)\nON DUPLICATE KEY UPDATE a = VALUES(a)",
+		},
+		{
+			query: "DELETE FROM t WHERE a IS DISTINCT FROM b",
+			want:  "DELETE FROM t\nWHERE a IS DISTINCT FROM b",
+		},
+		{
+			query: "SELECT DISTINCT * FROM t WHERE a IS NOT DISTINCT FROM b",
+			want:  "SELECT DISTINCT *\nFROM t\nWHERE a IS NOT DISTINCT FROM b",
+		},
+		{
+			query: "SELECT a FROM t LIMIT 1 FOR UPDATE SKIP LOCKED",
+			want:  "SELECT a\nFROM t\nLIMIT 1\nFOR UPDATE SKIP LOCKED",
+		},
+		{
+			query: "SELECT 'unterminated ) FROM",
+			want:  "SELECT 'unterminated ) FROM",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.query, func(t *testing.T) {
+			t.Parallel()
+			got := sqlfmt.Format(tc.query)
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
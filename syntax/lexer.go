@@ -101,6 +101,25 @@ func (l *lexerHelper) SkipWhitespace() {
 	}
 }
 
+// ScanQuoted scans the quoted string or identifier starting at the current
+// quoter rune, which could be one of ', " and `, and moves to the rune
+// after the closing quoter. A single quote is escaped by doubling it.
+// It tells if the closing quoter is found before EOF.
+func (l *lexerHelper) ScanQuoted() bool {
+	quoter := l.rune
+	for r := l.Next(); r != EOF; r = l.Next() {
+		if r == quoter {
+			if quoter == '\'' && l.Peek() == '\'' {
+				l.Next()
+				continue
+			}
+			l.Next()
+			return true
+		}
+	}
+	return false
+}
+
 // IsEOF tells if it reaches the EOF
 func (l *lexerHelper) IsEOF() bool {
 	return l.pos >= len(l.input)
@@ -100,20 +100,8 @@ func scanIndex(s *scanner) scanFn {
 }
 
 func scanQuotedPlain(s *scanner) scanFn {
-	quoter := s.rune
-	for r := s.Next(); r != EOF; r = s.Next() {
-		if r == quoter {
-			if quoter == '\'' && s.Peek() == '\'' {
-				s.Next()
-				continue
-			}
-			s.Next()
-			s.emitToken(_Plain, _StringLit, false)
-			return scanPlain
-		}
-	}
-	// EOF
-	s.emitToken(_Plain, _StringLit, true)
+	closed := s.ScanQuoted()
+	s.emitToken(_Plain, _StringLit, !closed)
 	return scanPlain
 }
 
@@ -196,17 +184,9 @@ func scanFuncArgs(s *scanner) scanFn {
 }
 
 func scanFuncArgQuoted(s *scanner) scanFn {
-	quoter := s.rune
-	for r := s.Next(); r != EOF; r = s.Next() {
-		if r == quoter {
-			if quoter == '\'' && s.Peek() == '\'' {
-				s.Next()
-				continue
-			}
-			s.Next()
-			s.emitToken(_Literal, _StringLit, false)
-			return scanFuncArgs
-		}
+	if s.ScanQuoted() {
+		s.emitToken(_Literal, _StringLit, false)
+		return scanFuncArgs
 	}
 	// EOF
 	s.emitToken(_Literal, _StringLit, true)
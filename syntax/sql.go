@@ -0,0 +1,94 @@
+package syntax
+
+// SQLToken is a lexical token of a built SQL query.
+type SQLToken struct {
+	Type SQLTokenType
+	Text string
+	Pos  Pos
+	Bad  bool // the token is not terminated, e.g. a quoted string without closing quoter
+}
+
+// SQLTokenType is the type of SQLToken.
+type SQLTokenType int
+
+const (
+	// SQLWord is a keyword, identifier or number, e.g.: SELECT, t, id, 1
+	SQLWord SQLTokenType = iota
+	// SQLQuoted is a quoted string or identifier, e.g.: 'a', "a", `a`
+	SQLQuoted
+	// SQLComment is a line or block comment, e.g.: -- a, /* a */
+	SQLComment
+	// SQLBindVar is a bindvar, e.g.: $1, ?
+	SQLBindVar
+	// SQLSpace is a sequence of whitespaces.
+	SQLSpace
+	// SQLPunct is a punctuation or operator rune, e.g.: (, ), ',', ., =
+	SQLPunct
+)
+
+// ScanSQL splits the SQL query into tokens. Unlike Parse, it works on the
+// built query rather than the segment template, and the quoted strings,
+// comments and bindvars are kept intact as single tokens. Concatenating the
+// Text of all tokens produces the input.
+func ScanSQL(query string) []*SQLToken {
+	l := newLexerHelper(query)
+	tokens := make([]*SQLToken, 0)
+	emit := func(typ SQLTokenType, bad bool) {
+		tokens = append(tokens, &SQLToken{
+			Type: typ,
+			Text: query[l.start:l.pos],
+			Pos:  l.startPos,
+			Bad:  bad,
+		})
+	}
+	for {
+		l.StartToken()
+		if l.IsEOF() {
+			break
+		}
+		switch r := l.rune; {
+		case l.IsWhitespace():
+			for !l.IsEOF() && l.IsWhitespace() {
+				l.Next()
+			}
+			emit(SQLSpace, false)
+		case r == '\'' || r == '"' || r == '`':
+			closed := l.ScanQuoted()
+			emit(SQLQuoted, !closed)
+		case r == '-' && l.Peek() == '-':
+			for !l.IsEOF() && l.rune != '\n' {
+				l.Next()
+			}
+			emit(SQLComment, false)
+		case r == '/' && l.Peek() == '*':
+			l.Next()
+			closed := false
+			for r := l.Next(); !l.IsEOF(); r = l.Next() {
+				if r == '*' && l.Peek() == '/' {
+					l.Next()
+					l.Next()
+					closed = true
+					break
+				}
+			}
+			emit(SQLComment, !closed)
+		case r == '?':
+			l.Next()
+			emit(SQLBindVar, false)
+		case r == '$' && '0' <= l.Peek() && l.Peek() <= '9':
+			for l.Next(); !l.IsEOF() && l.IsDecimal(); {
+				l.Next()
+			}
+			emit(SQLBindVar, false)
+		case l.IsLetter() || l.IsDecimal():
+			for !l.IsEOF() && (l.IsLetter() || l.IsDecimal()) {
+				l.Next()
+			}
+			emit(SQLWord, false)
+		default:
+			l.Next()
+			emit(SQLPunct, false)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,61 @@
+package syntax_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/qjebbs/go-sqls/syntax"
+)
+
+func TestScanSQL(t *testing.T) {
+	type tk struct {
+		Type syntax.SQLTokenType
+		Text string
+		Bad  bool
+	}
+	testCases := []struct {
+		raw  string
+		want []tk
+	}{
+		{
+			raw: "SELECT 'a''b' -- c\n$1",
+			want: []tk{
+				{Type: syntax.SQLWord, Text: "SELECT"},
+				{Type: syntax.SQLSpace, Text: " "},
+				{Type: syntax.SQLQuoted, Text: "'a''b'"},
+				{Type: syntax.SQLSpace, Text: " "},
+				{Type: syntax.SQLComment, Text: "-- c"},
+				{Type: syntax.SQLSpace, Text: "\n"},
+				{Type: syntax.SQLBindVar, Text: "$1"},
+			},
+		},
+		{
+			raw: `t."a b"=?/* c */`,
+			want: []tk{
+				{Type: syntax.SQLWord, Text: "t"},
+				{Type: syntax.SQLPunct, Text: "."},
+				{Type: syntax.SQLQuoted, Text: `"a b"`},
+				{Type: syntax.SQLPunct, Text: "="},
+				{Type: syntax.SQLBindVar, Text: "?"},
+				{Type: syntax.SQLComment, Text: "/* c */"},
+			},
+		},
+		{
+			raw: "'a /* b",
+			want: []tk{
+				{Type: syntax.SQLQuoted, Text: "'a /* b", Bad: true},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.raw, func(t *testing.T) {
+			got := make([]tk, 0)
+			for _, token := range syntax.ScanSQL(tc.raw) {
+				got = append(got, tk{token.Type, token.Text, token.Bad})
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
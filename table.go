@@ -82,8 +82,13 @@ func (t Table) Expressions(expressions ...string) []*TableColumn {
 }
 
 // TableColumn is a column of a table.
+//
+// The Columns are the nested columns referenced by the Raw with #c1, #c2...,
+// which allows an expression to reference the columns of other tables, e.g.
+// a window function partitioned by the columns of joined tables.
 type TableColumn struct {
-	Table Table
-	Raw   string
-	Args  []any
+	Table   Table
+	Raw     string
+	Args    []any
+	Columns []*TableColumn
 }
@@ -59,6 +59,9 @@ func Scan[T any](db QueryAble, query string, args []any, fn NewScanDestFunc[T])
 }
 
 // CountBuilder is like Count, but it builds query from sqls.Builder.
+//
+// For *sqlb.QueryBuilder, consider counting with its CountBuilder(), which
+// derives a cheaper query without ORDER BY, LIMIT and unneeded joins.
 func CountBuilder(db QueryAble, b sqls.Builder) (count int64, err error) {
 	query, args, err := b.Build()
 	if err != nil {